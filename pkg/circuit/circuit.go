@@ -0,0 +1,215 @@
+// Package circuit implements the circuit breaker both services put in front
+// of their upstreams, so a failing upstream is failed fast instead of
+// waited on by every request.
+package circuit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OpenError is returned by Allow while the circuit is open.
+var OpenError = errors.New("circuit open")
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// HalfOpen lets a few probe calls through to test the upstream.
+	HalfOpen
+	// Open fails every call fast.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Outcome is the outcome of a call let through by a Breaker.
+type Outcome int
+
+const (
+	// Success resets the failures, and counts as a successful probe while
+	// half open.
+	Success Outcome = iota
+	// Failure counts against the circuit.
+	Failure
+	// Ignored neither counts as a success nor as a failure, e.g. for calls
+	// cancelled by the caller before the upstream answered. It frees the
+	// probe slot of the call while half open.
+	Ignored
+)
+
+// Defaults applied to zero Options fields.
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 30 * time.Second
+	DefaultHalfOpenProbes   = 1
+)
+
+type Options struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing the
+	// upstream again.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe calls let through while half
+	// open, all of which must succeed to close the circuit.
+	HalfOpenProbes int
+}
+
+// Breaker is a consecutive failures circuit breaker. It opens after
+// FailureThreshold failures in a row, fails calls fast for OpenTimeout and
+// then lets HalfOpenProbes calls through: the circuit closes when they all
+// succeed and opens again on the first failure.
+type Breaker struct {
+	name string
+	opts Options
+	now  func() time.Time
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	failures   int
+	openedAt   time.Time
+	probes     int
+	successes  int
+}
+
+// NewBreaker returns a closed Breaker, exported as name in metrics and
+// spans.
+func NewBreaker(name string, opts Options) *Breaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = DefaultOpenTimeout
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = DefaultHalfOpenProbes
+	}
+
+	b := &Breaker{name: name, opts: opts, now: time.Now}
+	telemetry.ObserveCircuit(name, func() int64 { return int64(b.State()) })
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, moving an open circuit whose timeout
+// elapsed to half open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(context.Background())
+	return b.state
+}
+
+// Available reports whether a call would be let through.
+func (b *Breaker) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(context.Background())
+	return b.state == Closed || (b.state == HalfOpen && b.probes < b.opts.HalfOpenProbes)
+}
+
+// Allow asks to make a call. It returns OpenError when the call must fail
+// fast, otherwise done must be called with the outcome of the call. The
+// state of the circuit is recorded on the span carried by ctx.
+func (b *Breaker) Allow(ctx context.Context) (done func(outcome Outcome), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh(ctx)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("circuit.name", b.name),
+		attribute.String("circuit.state", b.state.String()),
+	)
+
+	if b.state == Open || (b.state == HalfOpen && b.probes >= b.opts.HalfOpenProbes) {
+		span.AddEvent("circuit rejected call")
+		telemetry.RecordCircuitRejection(ctx, b.name)
+		return nil, OpenError
+	}
+
+	if b.state == HalfOpen {
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(outcome Outcome) { b.done(ctx, generation, outcome) }, nil
+}
+
+func (b *Breaker) done(ctx context.Context, generation uint64, outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Outcomes of calls made before the last transition are stale.
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		switch outcome {
+		case Success:
+			b.failures = 0
+		case Failure:
+			b.failures++
+			if b.failures >= b.opts.FailureThreshold {
+				b.transition(ctx, Open)
+			}
+		}
+	case HalfOpen:
+		switch outcome {
+		case Ignored:
+			b.probes--
+			return
+		case Failure:
+			b.transition(ctx, Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.opts.HalfOpenProbes {
+			b.transition(ctx, Closed)
+		}
+	}
+}
+
+// refresh moves an open circuit whose timeout elapsed to half open.
+func (b *Breaker) refresh(ctx context.Context) {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.opts.OpenTimeout {
+		b.transition(ctx, HalfOpen)
+	}
+}
+
+func (b *Breaker) transition(ctx context.Context, state State) {
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	if state == Open {
+		b.openedAt = b.now()
+	}
+	telemetry.RecordCircuitTransition(ctx, b.name, state.String())
+}
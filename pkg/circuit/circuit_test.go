@@ -0,0 +1,145 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(opts Options) (*Breaker, *clock) {
+	c := &clock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	b := NewBreaker("test", opts)
+	b.now = c.Now
+	return b, c
+}
+
+func call(t *testing.T, b *Breaker, outcome Outcome) error {
+	t.Helper()
+	done, err := b.Allow(context.Background())
+	if err != nil {
+		return err
+	}
+	done(outcome)
+	return nil
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(Options{FailureThreshold: 3})
+
+	call(t, b, Failure)
+	call(t, b, Failure)
+	call(t, b, Success)
+	call(t, b, Failure)
+	call(t, b, Failure)
+	if b.State() != Closed {
+		t.Fatalf("Expected a success to reset the failures, got %s", b.State())
+	}
+
+	call(t, b, Failure)
+	if b.State() != Open {
+		t.Fatalf("Expected open, got %s", b.State())
+	}
+	if err := call(t, b, Success); !errors.Is(err, OpenError) {
+		t.Errorf("Expected OpenError, got %v", err)
+	}
+	if b.Available() {
+		t.Error("Expected an open circuit to be unavailable")
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	b, c := newTestBreaker(Options{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenProbes: 2})
+
+	call(t, b, Failure)
+	c.now = c.now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected half-open after the timeout, got %s", b.State())
+	}
+
+	first, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(context.Background()); !errors.Is(err, OpenError) {
+		t.Errorf("Expected calls beyond the probes to fail fast, got %v", err)
+	}
+
+	first(Success)
+	if b.State() != HalfOpen {
+		t.Errorf("Expected half-open until every probe succeeds, got %s", b.State())
+	}
+	second(Success)
+	if b.State() != Closed {
+		t.Errorf("Expected closed, got %s", b.State())
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	b, c := newTestBreaker(Options{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	call(t, b, Failure)
+	c.now = c.now.Add(time.Minute)
+	call(t, b, Failure)
+	if b.State() != Open {
+		t.Fatalf("Expected a failed probe to reopen, got %s", b.State())
+	}
+
+	c.now = c.now.Add(30 * time.Second)
+	if b.Available() {
+		t.Error("Expected the open timeout to restart")
+	}
+}
+
+func TestBreakerIgnoresStaleOutcomes(t *testing.T) {
+	b, _ := newTestBreaker(Options{FailureThreshold: 1})
+
+	slow, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	call(t, b, Failure)
+
+	slow(Success)
+	if b.State() != Open {
+		t.Errorf("Expected a call started before opening not to close the circuit, got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	b, c := newTestBreaker(Options{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	call(t, b, Failure)
+	call(t, b, Ignored)
+	call(t, b, Failure)
+	if b.State() != Open {
+		t.Fatalf("Expected an ignored call not to reset the failures, got %s", b.State())
+	}
+
+	c.now = c.now.Add(time.Minute)
+	call(t, b, Ignored)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected an ignored probe not to close the circuit, got %s", b.State())
+	}
+	if !b.Available() {
+		t.Error("Expected an ignored probe to free its slot")
+	}
+
+	call(t, b, Success)
+	if b.State() != Closed {
+		t.Errorf("Expected closed, got %s", b.State())
+	}
+}
@@ -0,0 +1,110 @@
+// Package coalesce merges concurrent identical lookups into a single call,
+// shared by both services in front of their upstreams.
+package coalesce
+
+import (
+	"context"
+	"sync"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Group coalesces concurrent calls sharing a key: the first caller, the
+// leader, runs the call and the ones arriving while it is in flight wait
+// for its result.
+type Group[T any] struct {
+	name  string
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+type call[T any] struct {
+	done      chan struct{}
+	leader    trace.SpanContext
+	followers int
+	value     T
+	err       error
+}
+
+// NewGroup returns a Group labelled name in spans and metrics.
+func NewGroup[T any](name string) *Group[T] {
+	return &Group[T]{name: name, calls: map[string]*call[T]{}}
+}
+
+// Do returns the result of fn for key, calling it unless a call for key is
+// already in flight. Waiting callers link the span carried by their ctx to
+// the leader's one.
+//
+// fn runs detached from the leader's cancellation, bounded by its deadline,
+// so a leader going away does not fail the callers waiting on it. Every
+// caller stops waiting when its own ctx is done.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		c.followers++
+		g.mu.Unlock()
+
+		span := trace.SpanFromContext(ctx)
+		span.AddLink(trace.Link{
+			SpanContext: c.leader,
+			Attributes:  []attribute.KeyValue{attribute.String("coalesce.group", g.name)},
+		})
+		span.SetAttributes(attribute.Bool("coalesce.follower", true))
+		telemetry.RecordCoalescedRequest(ctx, g.name, true)
+
+		return c.wait(ctx)
+	}
+
+	c := &call[T]{done: make(chan struct{}), leader: trace.SpanContextFromContext(ctx)}
+	g.calls[key] = c
+	g.mu.Unlock()
+	telemetry.RecordCoalescedRequest(ctx, g.name, false)
+
+	callCtx, cancel := detach(ctx)
+	go func() {
+		defer cancel()
+
+		c.value, c.err = fn(callCtx)
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	return c.wait(ctx)
+}
+
+// Followers returns the number of callers waiting on the call in flight
+// for key, zero when there is none.
+func (g *Group[T]) Followers(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok := g.calls[key]; ok {
+		return c.followers
+	}
+	return 0
+}
+
+func (c *call[T]) wait(ctx context.Context) (T, error) {
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// detach returns a context carrying the values and deadline of ctx but not
+// its cancellation.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return context.WithCancel(detached)
+}
@@ -0,0 +1,134 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// waitFollowers waits until n callers are waiting on the call for key.
+func waitFollowers[T any](group *Group[T], key string, n int) {
+	for group.Followers(key) != n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGroupCoalescesConcurrentCalls(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	group := NewGroup[int]("test")
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	fn := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	leaderCtx, leaderSpan := tracer.Start(context.Background(), "leader")
+	results := make(chan int, 4)
+	go func() {
+		value, _ := group.Do(leaderCtx, "20561250", fn)
+		results <- value
+	}()
+
+	// Wait for the leader to be in flight.
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var followers sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		followers.Add(1)
+		go func() {
+			defer followers.Done()
+			ctx, span := tracer.Start(context.Background(), "follower")
+			defer span.End()
+			value, _ := group.Do(ctx, "20561250", fn)
+			results <- value
+		}()
+	}
+
+	waitFollowers(group, "20561250", 3)
+	close(release)
+	followers.Wait()
+	leaderSpan.End()
+
+	for i := 0; i < 4; i++ {
+		if value := <-results; value != 42 {
+			t.Errorf("Expected 42, got %d", value)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call, got %d", calls.Load())
+	}
+
+	for _, span := range recorder.Ended() {
+		if span.Name() != "follower" {
+			continue
+		}
+		links := span.Links()
+		if len(links) != 1 || links[0].SpanContext.SpanID() != leaderSpan.SpanContext().SpanID() {
+			t.Errorf("Expected a link to the leader span, got %+v", links)
+		}
+	}
+}
+
+func TestGroupLeaderCancellation(t *testing.T) {
+	group := NewGroup[string]("test")
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	fn := func(ctx context.Context) (string, error) {
+		close(started)
+		<-release
+		return "ok", ctx.Err()
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := group.Do(leaderCtx, "key", fn)
+		leaderErr <- err
+	}()
+	<-started
+
+	followerResult := make(chan error, 1)
+	go func() {
+		_, err := group.Do(context.Background(), "key", fn)
+		followerResult <- err
+	}()
+	waitFollowers(group, "key", 1)
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the leader to stop waiting, got %v", err)
+	}
+
+	close(release)
+	if err := <-followerResult; err != nil {
+		t.Errorf("Expected the follower to get the result, got %v", err)
+	}
+}
+
+func TestGroupSequentialCalls(t *testing.T) {
+	group := NewGroup[int]("test")
+	calls := 0
+	for i := 0; i < 2; i++ {
+		group.Do(context.Background(), "key", func(ctx context.Context) (int, error) {
+			calls++
+			return calls, nil
+		})
+	}
+	if calls != 2 {
+		t.Errorf("Expected completed calls not to be shared, got %d calls", calls)
+	}
+}
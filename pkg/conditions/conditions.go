@@ -0,0 +1,122 @@
+// Package conditions implements the opt-in extended current conditions
+// payload shared by both services.
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Field groups accepted by the fields query parameter.
+const (
+	Humidity      = "humidity"
+	Wind          = "wind"
+	Pressure      = "pressure"
+	Precipitation = "precipitation"
+	UV            = "uv"
+	Condition     = "condition"
+	FeelsLike     = "feels_like"
+	// All selects every field group.
+	All = "all"
+)
+
+var groups = []string{Humidity, Wind, Pressure, Precipitation, UV, Condition, FeelsLike}
+
+// Fields is a set of requested field groups. The zero value requests none,
+// keeping the compact response.
+type Fields map[string]bool
+
+// ParseFields parses a comma separated list of field groups.
+func ParseFields(raw string) (Fields, error) {
+	fields := Fields{}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "":
+			continue
+		case name == All:
+			for _, group := range groups {
+				fields[group] = true
+			}
+		case isGroup(name):
+			fields[name] = true
+		default:
+			return nil, fmt.Errorf("unknown field %q, expected one of %s or %s", name, strings.Join(groups, ", "), All)
+		}
+	}
+	return fields, nil
+}
+
+func isGroup(name string) bool {
+	for _, group := range groups {
+		if group == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Fields) String() string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// Extended holds the extended current conditions. Every field is omitted
+// from the JSON encoding when unset, so an empty Extended adds nothing to
+// the response embedding it.
+type Extended struct {
+	Humidity      *float64 `json:"humidity,omitempty"`
+	WindKph       *float64 `json:"wind_kph,omitempty"`
+	WindDegree    *int     `json:"wind_degree,omitempty"`
+	WindDir       string   `json:"wind_dir,omitempty"`
+	PressureHpa   *float64 `json:"pressure_hpa,omitempty"`
+	PrecipMm      *float64 `json:"precip_mm,omitempty"`
+	UV            *float64 `json:"uv,omitempty"`
+	Condition     string   `json:"condition,omitempty"`
+	ConditionCode *int     `json:"condition_code,omitempty"`
+	FeelsLike_C   *float64 `json:"feels_like_C,omitempty"`
+	FeelsLike_F   *float64 `json:"feels_like_F,omitempty"`
+	FeelsLike_K   *float64 `json:"feels_like_K,omitempty"`
+}
+
+// Filter returns the field groups of e present in fields.
+func (e Extended) Filter(fields Fields) Extended {
+	var filtered Extended
+	if fields[Humidity] {
+		filtered.Humidity = e.Humidity
+	}
+	if fields[Wind] {
+		filtered.WindKph, filtered.WindDegree, filtered.WindDir = e.WindKph, e.WindDegree, e.WindDir
+	}
+	if fields[Pressure] {
+		filtered.PressureHpa = e.PressureHpa
+	}
+	if fields[Precipitation] {
+		filtered.PrecipMm = e.PrecipMm
+	}
+	if fields[UV] {
+		filtered.UV = e.UV
+	}
+	if fields[Condition] {
+		filtered.Condition, filtered.ConditionCode = e.Condition, e.ConditionCode
+	}
+	if fields[FeelsLike] {
+		filtered.FeelsLike_C, filtered.FeelsLike_F, filtered.FeelsLike_K = e.FeelsLike_C, e.FeelsLike_F, e.FeelsLike_K
+	}
+	return filtered
+}
+
+var compass = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// WindDirection returns the 16 point compass direction of degree, the
+// notation WeatherAPI uses in wind_dir.
+func WindDirection(degree int) string {
+	i := int(math.Round(float64(((degree%360)+360)%360)/22.5)) % len(compass)
+	return compass[i]
+}
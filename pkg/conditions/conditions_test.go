@@ -0,0 +1,59 @@
+package conditions
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields("humidity, Wind,,uv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields.String() != "humidity,uv,wind" {
+		t.Errorf("Expected humidity,uv,wind, got %s", fields)
+	}
+
+	fields, err = ParseFields("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != len(groups) {
+		t.Errorf("Expected every group, got %s", fields)
+	}
+
+	if _, err := ParseFields("humidity,visibility"); err == nil {
+		t.Error("Expected error for an unknown field")
+	}
+}
+
+func TestExtendedFilter(t *testing.T) {
+	humidity, windKph, windDegree, code := 60.0, 12.6, 140, 1000
+	extended := Extended{
+		Humidity:      &humidity,
+		WindKph:       &windKph,
+		WindDegree:    &windDegree,
+		WindDir:       "SE",
+		Condition:     "Sunny",
+		ConditionCode: &code,
+	}
+
+	body, _ := json.Marshal(extended.Filter(Fields{Wind: true}))
+	if string(body) != `{"wind_kph":12.6,"wind_degree":140,"wind_dir":"SE"}` {
+		t.Errorf("Unexpected wind fields %s", body)
+	}
+
+	body, _ = json.Marshal(extended.Filter(nil))
+	if string(body) != `{}` {
+		t.Errorf("Expected no fields, got %s", body)
+	}
+}
+
+func TestWindDirection(t *testing.T) {
+	expected := map[int]string{0: "N", 11: "N", 12: "NNE", 140: "SE", 180: "S", 349: "N", 360: "N", -90: "W"}
+	for degree, direction := range expected {
+		if got := WindDirection(degree); got != direction {
+			t.Errorf("Expected %s for %d, got %s", direction, degree, got)
+		}
+	}
+}
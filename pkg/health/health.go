@@ -0,0 +1,227 @@
+// Package health implements the liveness and readiness endpoints both
+// services expose to docker compose and orchestrators.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Statuses reported by the endpoints.
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable, returning why when it is
+// not.
+type Check func(ctx context.Context) error
+
+// Report is the body answered by the endpoints. Checks are only reported
+// by detailed handlers.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type Result struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type namedCheck struct {
+	name     string
+	check    Check
+	critical bool
+}
+
+// Checker runs the readiness checks of a service. Failing critical checks
+// make it unavailable, failing optional ones only degraded.
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// NewChecker returns a Checker giving up on checks after timeout,
+// DefaultTimeout when zero.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Critical adds a check the service cannot serve without.
+func (c *Checker) Critical(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check, critical: true})
+}
+
+// Optional adds a check the service can serve, degraded, without.
+func (c *Checker) Optional(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run runs every check concurrently.
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: map[string]Result{}}
+	for i, result := range results {
+		report.Checks[c.checks[i].name] = result
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check namedCheck) Result {
+	start := time.Now()
+	err := check.check(ctx)
+	result := Result{
+		Status:   StatusOK,
+		Critical: check.critical,
+		Duration: time.Since(start).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Handler answers the readiness of the service: 200 when ok or degraded,
+// 503 when unavailable. The result of every check is only reported when
+// detailed is set, as it may disclose the dependencies of the service.
+func (c *Checker) Handler(detailed bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		if !detailed {
+			report.Checks = nil
+		}
+
+		status := http.StatusOK
+		if report.Status == StatusUnavailable {
+			status = http.StatusServiceUnavailable
+		}
+		write(w, status, report)
+	}
+}
+
+// Live answers the liveness of the service, which is alive as long as it
+// answers.
+func Live(w http.ResponseWriter, r *http.Request) {
+	write(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+func write(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Any returns a check passing when at least one of checks passes, e.g. for
+// providers failing over to each other.
+func Any(checks ...Check) Check {
+	return func(ctx context.Context) error {
+		errs := make([]error, len(checks))
+		for i, check := range checks {
+			if errs[i] = check(ctx); errs[i] == nil {
+				return nil
+			}
+		}
+		if len(errs) == 0 {
+			return errors.New("nothing to check")
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// All returns a check passing when every one of checks passes.
+func All(checks ...Check) Check {
+	return func(ctx context.Context) error {
+		for _, check := range checks {
+			if err := check(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Cached returns check remembering its outcome for ttl, for checks too
+// costly to run on every probe.
+func Cached(check Check, ttl time.Duration) Check {
+	var (
+		mu      sync.Mutex
+		err     error
+		checked time.Time
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return err
+		}
+		err = check(ctx)
+		// Checks cut short by the caller are not remembered.
+		if ctx.Err() == nil {
+			checked = time.Now()
+		}
+		return err
+	}
+}
+
+// Reachable returns a check passing when url answers a HEAD request within
+// timeout. Any answer below 500, e.g. 404 or 405 from a root path, shows
+// the upstream reachable.
+func Reachable(url string, timeout time.Duration) Check {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s answered %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
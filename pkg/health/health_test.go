@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	pass = func(ctx context.Context) error { return nil }
+	fail = func(ctx context.Context) error { return errors.New("down") }
+)
+
+func serve(t *testing.T, handler http.HandlerFunc) (int, Report) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, report
+}
+
+func TestCheckerHandler(t *testing.T) {
+	checker := NewChecker(0)
+	checker.Critical("service-b", pass)
+	checker.Optional("viacep", fail)
+
+	code, report := serve(t, checker.Handler(false))
+	if code != http.StatusOK || report.Status != StatusDegraded {
+		t.Errorf("Expected 200 degraded, got %d %s", code, report.Status)
+	}
+	if report.Checks != nil {
+		t.Errorf("Expected no details, got %v", report.Checks)
+	}
+
+	checker.Critical("weather", fail)
+	code, report = serve(t, checker.Handler(true))
+	if code != http.StatusServiceUnavailable || report.Status != StatusUnavailable {
+		t.Errorf("Expected 503 unavailable, got %d %s", code, report.Status)
+	}
+	if result := report.Checks["weather"]; result.Status != StatusUnavailable || !result.Critical || result.Error != "down" {
+		t.Errorf("Expected the failed weather check reported, got %+v", result)
+	}
+	if result := report.Checks["service-b"]; result.Status != StatusOK {
+		t.Errorf("Expected service-b ok, got %+v", result)
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Critical("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if report := checker.Run(context.Background()); report.Status != StatusUnavailable {
+		t.Errorf("Expected a timed out check to fail, got %s", report.Status)
+	}
+}
+
+func TestLive(t *testing.T) {
+	code, report := serve(t, Live)
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("Expected 200 ok, got %d %s", code, report.Status)
+	}
+}
+
+func TestAny(t *testing.T) {
+	if err := Any(fail, pass)(context.Background()); err != nil {
+		t.Errorf("Expected a passing check to be enough, got %v", err)
+	}
+	if err := Any(fail, fail)(context.Background()); err == nil {
+		t.Error("Expected an error when every check fails")
+	}
+	if err := Any()(context.Background()); err == nil {
+		t.Error("Expected an error without checks")
+	}
+}
+
+func TestAll(t *testing.T) {
+	if err := All(pass, pass)(context.Background()); err != nil {
+		t.Errorf("Expected every check to pass, got %v", err)
+	}
+	if err := All(pass, fail)(context.Background()); err == nil {
+		t.Error("Expected an error when a check fails")
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	check := Cached(func(ctx context.Context) error {
+		calls++
+		return nil
+	}, time.Hour)
+
+	check(context.Background())
+	check(context.Background())
+	if calls != 1 {
+		t.Errorf("Expected the outcome to be remembered, got %d calls", calls)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	status := http.StatusMethodNotAllowed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	if err := Reachable(server.URL, time.Second)(context.Background()); err != nil {
+		t.Errorf("Expected any answer below 500 to pass, got %v", err)
+	}
+
+	status = http.StatusBadGateway
+	if err := Reachable(server.URL, time.Second)(context.Background()); err == nil {
+		t.Error("Expected an error when the upstream answers 502")
+	}
+
+	if err := Reachable(server.URL+"/slow", 10*time.Millisecond)(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the probe to time out, got %v", err)
+	}
+
+	url := server.URL
+	server.Close()
+	if err := Reachable(url, time.Second)(context.Background()); err == nil {
+		t.Error("Expected an error when the upstream is down")
+	}
+}
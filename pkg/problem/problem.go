@@ -0,0 +1,138 @@
+// Package problem implements the RFC 7807 problem details error format
+// shared by both services.
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ContentType = "application/problem+json"
+
+// Machine readable codes carried in Problem.Code.
+const (
+	CodeInvalidZipcode      = "invalid_zipcode"
+	CodeInvalidParameter    = "invalid_parameter"
+	CodeZipcodeNotFound     = "zipcode_not_found"
+	CodeLocationNotFound    = "location_not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeRouteNotFound       = "route_not_found"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeUpstreamTimeout     = "upstream_timeout"
+	CodeUpstreamAuth        = "upstream_auth_failed"
+	CodeUpstreamRateLimited = "upstream_rate_limited"
+	CodeUpstreamBadPayload  = "upstream_bad_payload"
+	CodeCircuitOpen         = "circuit_open"
+	CodeInternal            = "internal_error"
+)
+
+type Problem struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     string    `json:"code"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	Upstream *Upstream `json:"upstream,omitempty"`
+}
+
+// Upstream describes the failure reported by a dependency. Upstreams nest
+// when the failure crossed several hops, e.g. service-a -> service-b -> viacep.
+type Upstream struct {
+	Name     string    `json:"name"`
+	Status   int       `json:"status,omitempty"`
+	Code     string    `json:"code,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	Upstream *Upstream `json:"upstream,omitempty"`
+}
+
+// New builds a problem whose type is derived from its code. title is the
+// human readable message, e.g. "invalid zipcode".
+func New(status int, code, title string) *Problem {
+	return &Problem{
+		Type:   "/problems/" + code,
+		Title:  title,
+		Status: status,
+		Code:   code,
+	}
+}
+
+func (p *Problem) WithDetail(detail string) *Problem {
+	p.Detail = detail
+	return p
+}
+
+func (p *Problem) WithUpstream(upstream *Upstream) *Problem {
+	p.Upstream = upstream
+	return p
+}
+
+// Write writes p as application/problem+json, filling the trace id from the
+// span context carried by ctx.
+func Write(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Problem) {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		p.TraceID = spanContext.TraceID().String()
+	}
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Parse reads a problem from an error response. Bodies that are not
+// problem+json are kept as the problem title so no detail is lost.
+func Parse(resp *http.Response) *Problem {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == ContentType {
+		p := &Problem{}
+		if err := json.Unmarshal(body, p); err == nil {
+			if p.Status == 0 {
+				p.Status = resp.StatusCode
+			}
+			return p
+		}
+	}
+
+	return &Problem{
+		Type:   "about:blank",
+		Title:  strings.TrimSpace(string(body)),
+		Status: resp.StatusCode,
+	}
+}
+
+// AsUpstream describes p as the failure of the named dependency.
+func (p *Problem) AsUpstream(name string) *Upstream {
+	detail := p.Detail
+	if detail == "" {
+		detail = p.Title
+	}
+
+	return &Upstream{
+		Name:     name,
+		Status:   p.Status,
+		Code:     p.Code,
+		Detail:   detail,
+		Upstream: p.Upstream,
+	}
+}
+
+// NotFound and MethodNotAllowed answer unknown routes with problems.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(r.Context(), w, r, New(http.StatusNotFound, CodeRouteNotFound, "route not found"))
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(r.Context(), w, r, New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed"))
+}
@@ -0,0 +1,63 @@
+package problem
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWriteAndParse(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/?cep=01001000", nil)
+	Write(ctx, recorder, request, New(http.StatusBadGateway, CodeUpstreamUnavailable, "upstream provider unavailable").
+		WithUpstream(&Upstream{Name: "viacep", Status: http.StatusInternalServerError}))
+
+	if recorder.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Expected %s, got %s", ContentType, recorder.Header().Get("Content-Type"))
+	}
+
+	p := Parse(recorder.Result())
+	if p.Status != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", p.Status)
+	}
+	if p.Code != CodeUpstreamUnavailable {
+		t.Errorf("Expected %s, got %s", CodeUpstreamUnavailable, p.Code)
+	}
+	if p.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected trace id, got %s", p.TraceID)
+	}
+	if p.Instance != "/" {
+		t.Errorf("Expected instance /, got %s", p.Instance)
+	}
+	if p.Upstream == nil || p.Upstream.Name != "viacep" {
+		t.Errorf("Expected viacep upstream, got %+v", p.Upstream)
+	}
+
+	upstream := p.AsUpstream("service-b")
+	if upstream.Detail != "upstream provider unavailable" || upstream.Upstream.Name != "viacep" {
+		t.Errorf("Unexpected upstream %+v", upstream)
+	}
+}
+
+func TestParsePlainText(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	http.Error(recorder, "invalid zipcode", http.StatusUnprocessableEntity)
+
+	p := Parse(recorder.Result())
+	if p.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", p.Status)
+	}
+	if p.Title != "invalid zipcode" {
+		t.Errorf("Expected invalid zipcode, got %s", p.Title)
+	}
+}
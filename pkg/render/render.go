@@ -0,0 +1,424 @@
+// Package render implements the temperature unit, precision and locale
+// selection shared by both services. It rewrites JSON responses in a
+// middleware, so handlers keep encoding every unit at full precision.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Query parameters, also accepted as parameters of the Accept header, e.g.
+// `Accept: application/json; units="C,F"; precision=1`.
+const (
+	UnitsParam     = "units"
+	PrecisionParam = "precision"
+	LocaleParam    = "locale"
+	// AutoLocale selects the first language of the Accept-Language header.
+	AutoLocale = "auto"
+)
+
+// responseUnits are the units responses carry, as temp_C, temp_F and temp_K
+// style fields.
+var responseUnits = []temperature.Unit{temperature.Celsius, temperature.Fahrenheit, temperature.Kelvin}
+
+// Options selects how temperatures are rendered. The zero value keeps the
+// response untouched.
+type Options struct {
+	// Units keeps only the temperature fields of these units, every unit
+	// when empty.
+	Units []temperature.Unit
+	// Precision rounds temperatures to this many decimals.
+	Precision *int
+	// Locale formats temperatures as localized strings, e.g. "24,1" for
+	// pt-BR. language.Und keeps JSON numbers.
+	Locale language.Tag
+}
+
+func (o Options) isZero() bool {
+	return len(o.Units) == 0 && o.Precision == nil && o.Locale == language.Und
+}
+
+// merge returns o with the options set in override replacing its own.
+func (o Options) merge(override Options) Options {
+	if len(override.Units) > 0 {
+		o.Units = override.Units
+	}
+	if override.Precision != nil {
+		o.Precision = override.Precision
+	}
+	if override.Locale != language.Und {
+		o.Locale = override.Locale
+	}
+	return o
+}
+
+// ParseOptions reads the options of r from its query parameters, falling
+// back to the parameters of its Accept header.
+func ParseOptions(r *http.Request) (Options, error) {
+	params := acceptParams(r.Header.Get("Accept"))
+	for _, name := range []string{UnitsParam, PrecisionParam, LocaleParam} {
+		if value := r.URL.Query().Get(name); value != "" {
+			params[name] = value
+		}
+	}
+
+	var options Options
+	if raw := params[UnitsParam]; raw != "" {
+		units, err := parseUnits(raw)
+		if err != nil {
+			return Options{}, err
+		}
+		options.Units = units
+	}
+
+	if raw := params[PrecisionParam]; raw != "" {
+		precision, err := strconv.Atoi(raw)
+		if err != nil || precision < 0 || precision > temperature.MaxPrecision {
+			return Options{}, fmt.Errorf("precision must be an integer between 0 and %d", temperature.MaxPrecision)
+		}
+		options.Precision = &precision
+	}
+
+	if raw := params[LocaleParam]; raw != "" {
+		locale, err := parseLocale(raw, r.Header.Get("Accept-Language"))
+		if err != nil {
+			return Options{}, err
+		}
+		options.Locale = locale
+	}
+
+	return options, nil
+}
+
+func parseUnits(raw string) ([]temperature.Unit, error) {
+	var units []temperature.Unit
+	for _, symbol := range strings.Split(raw, ",") {
+		if strings.TrimSpace(symbol) == "" {
+			continue
+		}
+		unit, err := temperature.ParseUnit(symbol)
+		if err != nil || !isResponseUnit(unit) {
+			return nil, fmt.Errorf("unknown unit %q, expected C, F or K", strings.TrimSpace(symbol))
+		}
+		units = append(units, unit)
+	}
+	return units, nil
+}
+
+func isResponseUnit(unit temperature.Unit) bool {
+	for _, u := range responseUnits {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+func parseLocale(raw, acceptLanguage string) (language.Tag, error) {
+	if strings.EqualFold(raw, AutoLocale) {
+		tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+		if err != nil || len(tags) == 0 {
+			return language.Und, nil
+		}
+		return tags[0], nil
+	}
+
+	locale, err := language.Parse(raw)
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid locale %q", raw)
+	}
+	return locale, nil
+}
+
+// acceptParams returns the parameters of the first JSON media range of
+// accept carrying any of ours.
+func acceptParams(accept string) map[string]string {
+	for _, mediaRange := range splitQuoted(accept, ',') {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+		default:
+			continue
+		}
+		if params[UnitsParam] != "" || params[PrecisionParam] != "" || params[LocaleParam] != "" {
+			return params
+		}
+	}
+	return map[string]string{}
+}
+
+// splitQuoted splits s at sep outside of double quotes.
+func splitQuoted(s string, sep rune) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Middleware applies the options requested by each request, on top of
+// defaults, to the application/json responses of next. Invalid options are
+// answered with 422.
+func Middleware(defaults Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested, err := ParseOptions(r)
+			if err != nil {
+				problem.Write(r.Context(), w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+					WithDetail(err.Error()))
+				return
+			}
+
+			options := defaults.merge(requested)
+			if options.isZero() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			buffered := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(buffered, r)
+
+			body := buffered.body.Bytes()
+			mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+			if mediaType == "application/json" {
+				if rewritten, err := options.Rewrite(body); err == nil {
+					body = rewritten
+				}
+			}
+
+			w.Header().Del("Content-Length")
+			w.WriteHeader(buffered.status)
+			w.Write(body)
+		})
+	}
+}
+
+// bufferedWriter holds the response of a handler until it is rewritten.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+// Rewrite applies the options to a JSON document, keeping the order of its
+// fields. Temperature fields are the ones named with a unit suffix, e.g.
+// temp_C or feels_like_K; their numbers and numeric strings are rounded and
+// formatted while every other value is copied as is.
+func (o Options) Rewrite(document []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var out bytes.Buffer
+	if err := o.rewriteValue(decoder, &out, ""); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after JSON document")
+	}
+	if bytes.HasSuffix(document, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+func (o Options) rewriteValue(decoder *json.Decoder, out *bytes.Buffer, key string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			return o.rewriteObject(decoder, out)
+		}
+		return o.rewriteArray(decoder, out)
+	case json.Number:
+		if _, ok := temperatureUnit(key); ok && o.formats() {
+			if f, err := value.Float64(); err == nil {
+				return o.writeNumber(out, f)
+			}
+		}
+		out.WriteString(value.String())
+	case string:
+		if _, ok := temperatureUnit(key); ok && o.formats() {
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				return writeString(out, o.formatString(f))
+			}
+		}
+		return writeString(out, value)
+	case bool:
+		out.WriteString(strconv.FormatBool(value))
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+func (o Options) rewriteObject(decoder *json.Decoder, out *bytes.Buffer) error {
+	out.WriteByte('{')
+	first := true
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		if unit, ok := temperatureUnit(key); ok && !o.keeps(unit) {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		if err := writeString(out, key); err != nil {
+			return err
+		}
+		out.WriteByte(':')
+		if err := o.rewriteValue(decoder, out, key); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+func (o Options) rewriteArray(decoder *json.Decoder, out *bytes.Buffer) error {
+	out.WriteByte('[')
+	for i := 0; decoder.More(); i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := o.rewriteValue(decoder, out, ""); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+// formats reports whether temperature values are rounded or localized,
+// rather than only filtered by unit.
+func (o Options) formats() bool {
+	return o.Precision != nil || o.Locale != language.Und
+}
+
+func (o Options) keeps(unit temperature.Unit) bool {
+	if len(o.Units) == 0 {
+		return true
+	}
+	for _, u := range o.Units {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNumber writes a temperature that was a JSON number. Localized
+// temperatures become strings, since JSON numbers have no locale.
+func (o Options) writeNumber(out *bytes.Buffer, value float64) error {
+	if o.Locale != language.Und {
+		return writeString(out, o.formatString(value))
+	}
+	if o.Precision != nil {
+		value = temperature.Round(value, *o.Precision)
+	}
+	out.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	return nil
+}
+
+// formatString formats a temperature written as a string, with exactly
+// Precision decimals when set.
+func (o Options) formatString(value float64) string {
+	decimals := -1
+	if o.Precision != nil {
+		decimals = *o.Precision
+		value = temperature.Round(value, decimals)
+	}
+
+	if o.Locale == language.Und {
+		return strconv.FormatFloat(value, 'f', decimals, 64)
+	}
+
+	printer := message.NewPrinter(o.Locale)
+	if decimals < 0 {
+		shortest := strconv.FormatFloat(value, 'f', -1, 64)
+		if i := strings.IndexByte(shortest, '.'); i >= 0 {
+			return printer.Sprint(number.Decimal(value, number.MaxFractionDigits(len(shortest)-i-1)))
+		}
+		return printer.Sprint(number.Decimal(value, number.MaxFractionDigits(0)))
+	}
+	return printer.Sprint(number.Decimal(value, number.Scale(decimals)))
+}
+
+func writeString(out *bytes.Buffer, value string) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	out.Write(encoded)
+	return nil
+}
+
+// temperatureUnit returns the unit named by the suffix of a temperature
+// field, e.g. K for temp_K.
+func temperatureUnit(key string) (temperature.Unit, bool) {
+	if len(key) < 3 || key[len(key)-2] != '_' {
+		return "", false
+	}
+	unit := temperature.Unit(key[len(key)-1:])
+	return unit, isResponseUnit(unit)
+}
@@ -0,0 +1,112 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"golang.org/x/text/language"
+)
+
+const document = `{"city":"São Paulo","temp_C":24.1234,"temp_F":75.42212,"temp_K":297.2734,"humidity":82,"feels_like_C":"25.500000","days":[{"max_temp_C":30.04,"max_temp_K":303.19}]}` + "\n"
+
+func parseRequest(t *testing.T, target string, header http.Header) Options {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	options, err := ParseOptions(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return options
+}
+
+func rewrite(t *testing.T, options Options) string {
+	t.Helper()
+	out, err := options.Rewrite([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRewrite(t *testing.T) {
+	expected := map[string]string{
+		"/?units=C":                          `{"city":"São Paulo","temp_C":24.1234,"humidity":82,"feels_like_C":"25.500000","days":[{"max_temp_C":30.04}]}` + "\n",
+		"/?units=c,k&precision=1":            `{"city":"São Paulo","temp_C":24.1,"temp_K":297.3,"humidity":82,"feels_like_C":"25.5","days":[{"max_temp_C":30,"max_temp_K":303.2}]}` + "\n",
+		"/?units=C&locale=pt-BR&precision=2": `{"city":"São Paulo","temp_C":"24,12","humidity":82,"feels_like_C":"25,50","days":[{"max_temp_C":"30,04"}]}` + "\n",
+		"/?units=F&locale=en-US":             `{"city":"São Paulo","temp_F":"75.42212","humidity":82,"days":[{}]}` + "\n",
+	}
+
+	for target, want := range expected {
+		if got := rewrite(t, parseRequest(t, target, nil)); got != want {
+			t.Errorf("%s: expected %s, got %s", target, want, got)
+		}
+	}
+}
+
+func TestParseOptionsAcceptHeaders(t *testing.T) {
+	options := parseRequest(t, "/?precision=2", http.Header{
+		"Accept":          {`text/html, application/json; units="C,K"; precision=0; locale=auto`},
+		"Accept-Language": {"pt-BR,pt;q=0.9,en;q=0.8"},
+	})
+
+	if len(options.Units) != 2 || options.Units[0] != "C" || options.Units[1] != "K" {
+		t.Errorf("Expected C and K, got %v", options.Units)
+	}
+	if options.Precision == nil || *options.Precision != 2 {
+		t.Errorf("Expected the query precision to win, got %v", options.Precision)
+	}
+	if options.Locale != language.MustParse("pt-BR") {
+		t.Errorf("Expected pt-BR, got %s", options.Locale)
+	}
+}
+
+func TestParseOptionsInvalid(t *testing.T) {
+	for _, target := range []string{"/?units=C,R", "/?units=X", "/?precision=-1", "/?precision=one", "/?locale=!!"} {
+		if _, err := ParseOptions(httptest.NewRequest(http.MethodGet, target, nil)); err == nil {
+			t.Errorf("%s: expected error", target)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	precision := 1
+	handler := Middleware(Options{Precision: &precision})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(document))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?units=K", nil))
+	if want := `{"city":"São Paulo","temp_K":297.3,"humidity":82,"days":[{"max_temp_K":303.2}]}` + "\n"; recorder.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?units=R", nil))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), problem.CodeInvalidParameter) {
+		t.Errorf("Expected %s, got %s", problem.CodeInvalidParameter, recorder.Body.String())
+	}
+}
+
+func TestMiddlewareWithoutOptions(t *testing.T) {
+	handler := Middleware(Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(document))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Body.String() != document {
+		t.Errorf("Expected the response untouched, got %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,259 @@
+// Package retry implements the retrying HTTP transport both services use
+// for their upstream calls.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults applied to zero Options fields.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 100 * time.Millisecond
+	DefaultMaxDelay    = 2 * time.Second
+)
+
+type Options struct {
+	// MaxAttempts bounds the attempts made for a request, the first one
+	// included.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for every
+	// following one.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. Answers asking with Retry-After for a
+	// longer delay are not retried.
+	MaxDelay time.Duration
+	// Budget caps retries across every request sharing it, unlimited when
+	// nil.
+	Budget *Budget
+}
+
+// Transport retries idempotent requests failing with a connection error
+// or a 5xx answer, waiting a jittered exponential backoff, or the delay
+// asked by Retry-After, between attempts. It never waits past the deadline
+// of the request context. Every attempt is recorded as an event on the span
+// carried by the request context.
+type Transport struct {
+	next http.RoundTripper
+	opts Options
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewTransport returns a Transport making its attempts with next,
+// http.DefaultTransport when nil.
+func NewTransport(next http.RoundTripper, opts Options) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+
+	return &Transport{
+		next: next,
+		opts: opts,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	span := trace.SpanFromContext(ctx)
+
+	if !retryable(request) {
+		return t.next.RoundTrip(request)
+	}
+	if t.opts.Budget != nil {
+		t.opts.Budget.deposit()
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptRequest, err := rewind(request, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := t.next.RoundTrip(attemptRequest)
+
+		attrs := []attribute.KeyValue{attribute.Int("http.attempt", attempt)}
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		} else {
+			attrs = append(attrs, attribute.Int("http.status_code", response.StatusCode))
+		}
+
+		delay, retry := t.backoff(ctx, attempt, response, err)
+		if retry {
+			retry = t.allow(ctx, delay, attempt)
+		}
+		if !retry {
+			span.AddEvent("http.request.attempt", trace.WithAttributes(attrs...))
+			return response, err
+		}
+
+		span.AddEvent("http.request.attempt", trace.WithAttributes(
+			append(attrs, attribute.Int64("retry.delay_ms", delay.Milliseconds()))...,
+		))
+		if response != nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// backoff reports whether the outcome of attempt is worth retrying and the
+// delay to wait before doing so.
+func (t *Transport) backoff(ctx context.Context, attempt int, response *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		// Errors caused by the request context are final.
+		final := ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+		return t.jitter(attempt), !final
+	}
+
+	retryAfter, hasRetryAfter := parseRetryAfter(response.Header.Get("Retry-After"))
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests && hasRetryAfter:
+	case response.StatusCode >= 500 && response.StatusCode != http.StatusNotImplemented:
+	default:
+		return 0, false
+	}
+
+	// Retrying sooner than asked would break the Retry-After contract, the
+	// answer is returned instead.
+	if hasRetryAfter {
+		return retryAfter, retryAfter <= t.opts.MaxDelay
+	}
+	return t.jitter(attempt), true
+}
+
+// allow reports whether another attempt may be made after delay, within
+// the attempts, the deadline of ctx and the budget.
+func (t *Transport) allow(ctx context.Context, delay time.Duration, attempt int) bool {
+	if attempt >= t.opts.MaxAttempts {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		trace.SpanFromContext(ctx).AddEvent("retry skipped past deadline")
+		return false
+	}
+	if t.opts.Budget != nil && !t.opts.Budget.withdraw() {
+		trace.SpanFromContext(ctx).AddEvent("retry budget exhausted")
+		return false
+	}
+	return true
+}
+
+// jitter returns a random delay up to the exponential backoff of attempt,
+// so clients retrying together spread their attempts.
+func (t *Transport) jitter(attempt int) time.Duration {
+	backoff := t.opts.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > t.opts.MaxDelay {
+		backoff = t.opts.MaxDelay
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Duration(t.rand.Int63n(int64(backoff) + 1))
+}
+
+// retryable reports whether request can be sent again: idempotent methods
+// whose body, if any, can be replayed.
+func retryable(request *http.Request) bool {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// rewind returns the request to send for attempt, with a fresh body.
+func rewind(request *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 {
+		return request, nil
+	}
+
+	clone := request.Clone(request.Context())
+	if request.Body != nil && request.Body != http.NoBody {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP
+// date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// Budget caps retries to a share of the requests made, so a failing
+// upstream does not get its load multiplied. Every request earns Ratio of
+// a retry, up to Burst saved retries, and every retry spends one.
+type Budget struct {
+	ratio float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewBudget returns a full Budget allowing ratio retries per request, e.g.
+// 0.2 for one retry every five requests, and up to burst retries in a row.
+func NewBudget(ratio float64, burst int) *Budget {
+	return &Budget{ratio: ratio, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.burst)
+}
+
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
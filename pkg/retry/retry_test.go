@@ -0,0 +1,219 @@
+package retry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// statusServer answers the statuses in order, then 200.
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func fastOptions() Options {
+	return Options{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+func TestTransportRetriesServerErrors(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	server, calls := statusServer(t, http.StatusBadGateway, http.StatusServiceUnavailable)
+	client := &http.Client{Transport: NewTransport(nil, fastOptions())}
+
+	ctx, span := tracer.Start(context.Background(), "request")
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	response, err := client.Do(request)
+	span.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("Expected 200 after 3 attempts, got %d after %d", response.StatusCode, calls.Load())
+	}
+	if events := recorder.Ended()[0].Events(); len(events) != 3 {
+		t.Errorf("Expected an event per attempt, got %d", len(events))
+	}
+}
+
+func TestTransportStopsAfterMaxAttempts(t *testing.T) {
+	server, calls := statusServer(t, 500, 500, 500, 500)
+	client := &http.Client{Transport: NewTransport(nil, fastOptions())}
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusInternalServerError || calls.Load() != DefaultMaxAttempts {
+		t.Errorf("Expected the last 500 after %d attempts, got %d after %d", DefaultMaxAttempts, response.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportDoesNotRetry(t *testing.T) {
+	expected := map[string]int{
+		"client error": http.StatusNotFound,
+		"rate limited": http.StatusTooManyRequests,
+		"unsupported":  http.StatusNotImplemented,
+	}
+	for name, status := range expected {
+		server, calls := statusServer(t, status)
+		client := &http.Client{Transport: NewTransport(nil, fastOptions())}
+
+		response, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if calls.Load() != 1 {
+			t.Errorf("%s: expected 1 attempt, got %d", name, calls.Load())
+		}
+	}
+
+	server, calls := statusServer(t, http.StatusBadGateway)
+	client := &http.Client{Transport: NewTransport(nil, fastOptions())}
+	response, err := client.Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("Expected POST not to be retried, got %d attempts", calls.Load())
+	}
+}
+
+func TestTransportHonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var first time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if waited := time.Since(first); waited < 900*time.Millisecond {
+			t.Errorf("Expected to wait for Retry-After, waited %s", waited)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, Options{MaxDelay: 2 * time.Second})}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestTransportReturnsLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, Options{MaxDelay: time.Second})}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("Expected the 503 returned without retrying, got %d after %d attempts", response.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportRespectsDeadline(t *testing.T) {
+	server, calls := statusServer(t, 503, 503, 503)
+	client := &http.Client{Transport: NewTransport(nil, Options{BaseDelay: time.Second, MaxDelay: time.Second, MaxAttempts: 5})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	start := time.Now()
+	response, err := client.Do(request)
+	if err == nil {
+		response.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up within the deadline, took %s", elapsed)
+	}
+	if calls.Load() > 2 {
+		t.Errorf("Expected no attempt past the deadline, got %d", calls.Load())
+	}
+}
+
+func TestTransportRetriesConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	var attempts atomic.Int32
+	next := roundTripper(func(r *http.Request) (*http.Response, error) {
+		attempts.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	client := &http.Client{Transport: NewTransport(next, fastOptions())}
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("Expected a connection error")
+	}
+	if attempts.Load() != DefaultMaxAttempts {
+		t.Errorf("Expected %d attempts, got %d", DefaultMaxAttempts, attempts.Load())
+	}
+}
+
+func TestBudget(t *testing.T) {
+	budget := NewBudget(0.5, 1)
+	server, calls := statusServer(t, 500, 500, 500, 500, 500, 500)
+	client := &http.Client{Transport: NewTransport(nil, Options{BaseDelay: time.Millisecond, MaxAttempts: 10, Budget: budget})}
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	// The full budget saves a single retry.
+	if calls.Load() != 2 {
+		t.Errorf("Expected the budget to allow a single retry, got %d attempts", calls.Load())
+	}
+
+	budget.deposit()
+	budget.deposit()
+	if !budget.withdraw() || budget.withdraw() {
+		t.Error("Expected two requests to earn one retry")
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,36 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	cacheOnce    sync.Once
+	cacheLookups metric.Int64Counter
+)
+
+// RecordCacheLookup counts one lookup in the named cache labelled by result,
+// hit or miss.
+func RecordCacheLookup(ctx context.Context, name string, hit bool) {
+	cacheOnce.Do(func() {
+		cacheLookups, _ = otel.Meter(meterName).Int64Counter(
+			"cache.lookup.count",
+			metric.WithDescription("Number of cache lookups"),
+		)
+	})
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheLookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("cache", name),
+		attribute.String("result", result),
+	))
+}
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type circuitInstruments struct {
+	transitions metric.Int64Counter
+	rejections  metric.Int64Counter
+}
+
+var (
+	circuitOnce    sync.Once
+	circuitMetrics circuitInstruments
+
+	circuitMu     sync.Mutex
+	circuitStates = map[string]func() int64{}
+)
+
+func circuit() circuitInstruments {
+	circuitOnce.Do(func() {
+		meter := otel.Meter(meterName)
+		circuitMetrics.transitions, _ = meter.Int64Counter(
+			"circuit.transition.count",
+			metric.WithDescription("Number of circuit breaker state changes"),
+		)
+		circuitMetrics.rejections, _ = meter.Int64Counter(
+			"circuit.rejected.count",
+			metric.WithDescription("Number of calls failed fast by an open circuit breaker"),
+		)
+		meter.Int64ObservableGauge(
+			"circuit.state",
+			metric.WithDescription("Circuit breaker state: 0 closed, 1 half-open, 2 open"),
+			metric.WithInt64Callback(func(ctx context.Context, observer metric.Int64Observer) error {
+				circuitMu.Lock()
+				defer circuitMu.Unlock()
+				for name, state := range circuitStates {
+					observer.Observe(state(), metric.WithAttributes(attribute.String("circuit", name)))
+				}
+				return nil
+			}),
+		)
+	})
+	return circuitMetrics
+}
+
+// ObserveCircuit exports the state of the named circuit breaker, read from
+// state on every collection.
+func ObserveCircuit(name string, state func() int64) {
+	circuit()
+
+	circuitMu.Lock()
+	defer circuitMu.Unlock()
+	circuitStates[name] = state
+}
+
+// RecordCircuitTransition counts the named circuit breaker moving to state.
+func RecordCircuitTransition(ctx context.Context, name, state string) {
+	circuit().transitions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("circuit", name),
+		attribute.String("state", state),
+	))
+}
+
+// RecordCircuitRejection counts one call failed fast by the named circuit
+// breaker.
+func RecordCircuitRejection(ctx context.Context, name string) {
+	circuit().rejections.Add(ctx, 1, metric.WithAttributes(attribute.String("circuit", name)))
+}
@@ -0,0 +1,37 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	coalesceOnce     sync.Once
+	coalescedLookups metric.Int64Counter
+)
+
+// RecordCoalescedRequest counts one request to the named coalescing group,
+// labelled by role: leader when it made the call, follower when it waited
+// for the leader's result.
+func RecordCoalescedRequest(ctx context.Context, group string, follower bool) {
+	coalesceOnce.Do(func() {
+		coalescedLookups, _ = otel.Meter(meterName).Int64Counter(
+			"coalesce.request.count",
+			metric.WithDescription("Number of requests going through request coalescing"),
+		)
+	})
+
+	role := "leader"
+	if follower {
+		role = "follower"
+	}
+
+	coalescedLookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("group", group),
+		attribute.String("role", role),
+	))
+}
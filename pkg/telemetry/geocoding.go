@@ -0,0 +1,35 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	geocodingOnce    sync.Once
+	geocodingLookups metric.Int64Counter
+)
+
+// RecordGeocodingLookup counts one lookup in the geocoding table labelled by
+// result, found or missing.
+func RecordGeocodingLookup(ctx context.Context, found bool) {
+	geocodingOnce.Do(func() {
+		geocodingLookups, _ = otel.Meter(meterName).Int64Counter(
+			"geocoding.lookup.count",
+			metric.WithDescription("Number of municipality lookups in the geocoding table"),
+		)
+	})
+
+	result := "missing"
+	if found {
+		result = "found"
+	}
+
+	geocodingLookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("result", result),
+	))
+}
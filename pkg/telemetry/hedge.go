@@ -0,0 +1,37 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	hedgeOnce     sync.Once
+	hedgeRequests metric.Int64Counter
+)
+
+// RecordHedgedRequest counts one hedge request fired by the named group to
+// provider, labelled by outcome: won when it answered first, lost otherwise.
+func RecordHedgedRequest(ctx context.Context, group, provider string, won bool) {
+	hedgeOnce.Do(func() {
+		hedgeRequests, _ = otel.Meter(meterName).Int64Counter(
+			"hedge.request.count",
+			metric.WithDescription("Number of hedge requests fired after a slow upstream call"),
+		)
+	})
+
+	outcome := "lost"
+	if won {
+		outcome = "won"
+	}
+
+	hedgeRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("group", group),
+		attribute.String("provider", provider),
+		attribute.String("outcome", outcome),
+	))
+}
@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const meterName = "github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+
+// durationBuckets are the histogram boundaries, in seconds, used for request
+// latencies.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// InitMeterProvider registers a meter provider backed by a Prometheus
+// exporter and returns the handler serving the collected metrics together
+// with the provider shutdown func.
+func InitMeterProvider(serviceName string) (http.Handler, func(context.Context) error, error) {
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mp := metric.NewMeterProvider(
+		metric.WithReader(exporter),
+		metric.WithResource(
+			resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceNameKey.String(serviceName),
+			),
+		),
+	)
+	otel.SetMeterProvider(mp)
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), mp.Shutdown, nil
+}
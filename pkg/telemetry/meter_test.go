@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	metricsHandler, shutdown, err := InitMeterProvider("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	r := chi.NewRouter()
+	r.Use(HTTPMetrics)
+	r.Get("/cep/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	r.Handle("/metrics", metricsHandler)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cep/01001000", nil))
+	RecordUpstreamCall(context.Background(), "viacep", OutcomeNotFound, time.Now())
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+
+	for _, expected := range []string{
+		`http_server_request_count_total{http_method="GET",http_route="/cep/{cep}",http_status_code="502"`,
+		`http_server_error_count_total{http_method="GET",http_route="/cep/{cep}",http_status_code="502"`,
+		`http_server_request_duration_seconds_bucket{http_method="GET",http_route="/cep/{cep}",http_status_code="502"`,
+		`outcome="not_found",upstream="viacep"} 1`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", expected, body)
+		}
+	}
+}
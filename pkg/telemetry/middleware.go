@@ -0,0 +1,80 @@
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type serverInstruments struct {
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+var (
+	serverOnce    sync.Once
+	serverMetrics serverInstruments
+)
+
+func server() serverInstruments {
+	serverOnce.Do(func() {
+		meter := otel.Meter(meterName)
+		serverMetrics.requests, _ = meter.Int64Counter(
+			"http.server.request.count",
+			metric.WithDescription("Number of HTTP requests handled"),
+		)
+		serverMetrics.errors, _ = meter.Int64Counter(
+			"http.server.error.count",
+			metric.WithDescription("Number of HTTP requests answered with a 5xx status"),
+		)
+		serverMetrics.duration, _ = meter.Float64Histogram(
+			"http.server.request.duration",
+			metric.WithDescription("Duration of HTTP requests"),
+			metric.WithUnit("s"),
+			metric.WithExplicitBucketBoundaries(durationBuckets...),
+		)
+	})
+	return serverMetrics
+}
+
+// HTTPMetrics is a chi middleware recording request count, errors and latency
+// per route pattern, method and status code.
+func HTTPMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := "unknown"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		attrs := metric.WithAttributes(
+			attribute.String("http.route", route),
+			attribute.String("http.method", r.Method),
+			attribute.String("http.status_code", strconv.Itoa(status)),
+		)
+
+		instruments := server()
+		instruments.requests.Add(r.Context(), 1, attrs)
+		instruments.duration.Record(r.Context(), time.Since(start).Seconds(), attrs)
+		if status >= http.StatusInternalServerError {
+			instruments.errors.Add(r.Context(), 1, attrs)
+		}
+	})
+}
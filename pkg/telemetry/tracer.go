@@ -0,0 +1,156 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const (
+	ExporterZipkin   = "zipkin"
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterStdout   = "stdout"
+	ExporterNone     = "none"
+)
+
+var defaultEndpoints = map[string]string{
+	ExporterZipkin:   "http://zipkin:9411/api/v2/spans",
+	ExporterOTLPGRPC: "otel-collector:4317",
+	ExporterOTLPHTTP: "otel-collector:4318",
+}
+
+type Config struct {
+	ServiceName string
+	// Exporter is one of zipkin, otlp-grpc, otlp-http, stdout or none.
+	// Defaults to zipkin.
+	Exporter string
+	// Endpoint overrides the exporter default. OTLP exporters accept either
+	// host:port or a full URL.
+	Endpoint string
+	Headers  map[string]string
+	// Insecure disables TLS for OTLP exporters.
+	Insecure bool
+}
+
+// InitTracerProvider builds the exporter selected by cfg, registers a tracer
+// provider and the trace context propagator globally, and returns the
+// provider shutdown func, which flushes any pending spans.
+func InitTracerProvider(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []trace.TracerProviderOption{
+		trace.WithSampler(trace.AlwaysSample()),
+		trace.WithResource(
+			resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceNameKey.String(cfg.ServiceName),
+			),
+		),
+	}
+	if exporter != nil {
+		options = append(options, trace.WithSpanProcessor(trace.NewBatchSpanProcessor(exporter)))
+	}
+
+	tp := trace.NewTracerProvider(options...)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (trace.SpanExporter, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Exporter))
+	if name == "" {
+		name = ExporterZipkin
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoints[name]
+	}
+
+	switch name {
+	case ExporterZipkin:
+		client := &http.Client{Transport: headerTransport{headers: cfg.Headers, next: http.DefaultTransport}}
+		return zipkin.New(endpoint, zipkin.WithClient(client))
+	case ExporterOTLPGRPC:
+		options := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(cfg.Headers)}
+		if strings.Contains(endpoint, "://") {
+			options = append(options, otlptracegrpc.WithEndpointURL(endpoint))
+		} else {
+			options = append(options, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, options...)
+	case ExporterOTLPHTTP:
+		options := []otlptracehttp.Option{otlptracehttp.WithHeaders(cfg.Headers)}
+		if strings.Contains(endpoint, "://") {
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+		} else {
+			options = append(options, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", cfg.Exporter)
+	}
+}
+
+// headerTransport adds static headers to every request, the zipkin exporter
+// has no option for them.
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// ParseHeaders parses a comma separated list of key=value pairs, the format
+// used by the TRACES_EXPORTER_HEADERS variable.
+func ParseHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers
+}
@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers := ParseHeaders("api-key=secret, x-tenant = lab2,invalid,=empty")
+
+	if len(headers) != 2 {
+		t.Fatalf("Expected 2 headers, got %v", headers)
+	}
+	if headers["api-key"] != "secret" {
+		t.Errorf("Expected secret, got %s", headers["api-key"])
+	}
+	if headers["x-tenant"] != "lab2" {
+		t.Errorf("Expected lab2, got %s", headers["x-tenant"])
+	}
+}
+
+func TestInitTracerProvider(t *testing.T) {
+	ctx := context.Background()
+
+	for _, exporter := range []string{"", "zipkin", "OTLP-GRPC", "otlp-http", "stdout", "none"} {
+		shutdown, err := InitTracerProvider(ctx, Config{ServiceName: "test", Exporter: exporter, Insecure: true})
+		if err != nil {
+			t.Fatalf("Unexpected error for exporter %q: %v", exporter, err)
+		}
+		if err := shutdown(ctx); err != nil {
+			t.Errorf("Unexpected shutdown error for exporter %q: %v", exporter, err)
+		}
+	}
+
+	if _, err := InitTracerProvider(ctx, Config{ServiceName: "test", Exporter: "jaeger"}); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+}
@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Outcomes used to label upstream calls.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+type upstreamInstruments struct {
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+var (
+	upstreamOnce    sync.Once
+	upstreamMetrics upstreamInstruments
+)
+
+func upstream() upstreamInstruments {
+	upstreamOnce.Do(func() {
+		meter := otel.Meter(meterName)
+		upstreamMetrics.requests, _ = meter.Int64Counter(
+			"upstream.request.count",
+			metric.WithDescription("Number of calls made to upstream providers"),
+		)
+		upstreamMetrics.duration, _ = meter.Float64Histogram(
+			"upstream.request.duration",
+			metric.WithDescription("Duration of calls made to upstream providers"),
+			metric.WithUnit("s"),
+			metric.WithExplicitBucketBoundaries(durationBuckets...),
+		)
+	})
+	return upstreamMetrics
+}
+
+// RecordUpstreamCall records one call to the named upstream, started at
+// start, labelled by its outcome.
+func RecordUpstreamCall(ctx context.Context, name, outcome string, start time.Time) {
+	attrs := metric.WithAttributes(
+		attribute.String("upstream", name),
+		attribute.String("outcome", outcome),
+	)
+
+	instruments := upstream()
+	instruments.requests.Add(ctx, 1, attrs)
+	instruments.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+}
@@ -0,0 +1,156 @@
+// Package temperature implements the temperature conversions and derived
+// metrics shared by both services, so they agree on every reading.
+package temperature
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Unit is a temperature scale.
+type Unit string
+
+const (
+	Celsius    Unit = "C"
+	Fahrenheit Unit = "F"
+	Kelvin     Unit = "K"
+	Rankine    Unit = "R"
+)
+
+// Units lists every supported scale.
+var Units = []Unit{Celsius, Fahrenheit, Kelvin, Rankine}
+
+const (
+	// AbsoluteZero is the lowest possible temperature, in Celsius.
+	AbsoluteZero = -273.15
+	// DefaultPrecision is the number of decimals readings are rounded to
+	// when no precision is requested.
+	DefaultPrecision = 1
+	// MaxPrecision is the highest precision Round honours, beyond it a
+	// float64 reading carries no extra information.
+	MaxPrecision = 15
+)
+
+// ParseUnit parses a unit symbol, case insensitive.
+func ParseUnit(raw string) (Unit, error) {
+	unit := Unit(strings.ToUpper(strings.TrimSpace(raw)))
+	for _, u := range Units {
+		if u == unit {
+			return unit, nil
+		}
+	}
+	return "", fmt.Errorf("unknown temperature unit %q, expected one of C, F, K or R", raw)
+}
+
+// Convert converts value from one scale to another. Conversions go through
+// Celsius using the exact definitions of each scale.
+func Convert(value float64, from, to Unit) float64 {
+	if from == to {
+		return value
+	}
+	return FromCelsius(ToCelsius(value, from), to)
+}
+
+// ToCelsius converts value in unit to Celsius.
+func ToCelsius(value float64, unit Unit) float64 {
+	switch unit {
+	case Fahrenheit:
+		return (value - 32) * 5 / 9
+	case Kelvin:
+		return value + AbsoluteZero
+	case Rankine:
+		return value*5/9 + AbsoluteZero
+	default:
+		return value
+	}
+}
+
+// FromCelsius converts a Celsius value to unit.
+func FromCelsius(celsius float64, unit Unit) float64 {
+	switch unit {
+	case Fahrenheit:
+		return celsius*9/5 + 32
+	case Kelvin:
+		return celsius - AbsoluteZero
+	case Rankine:
+		return (celsius - AbsoluteZero) * 9 / 5
+	default:
+		return celsius
+	}
+}
+
+// ToFahrenheit converts a Celsius value to Fahrenheit.
+func ToFahrenheit(celsius float64) float64 {
+	return FromCelsius(celsius, Fahrenheit)
+}
+
+// ToKelvin converts a Celsius value to Kelvin.
+func ToKelvin(celsius float64) float64 {
+	return FromCelsius(celsius, Kelvin)
+}
+
+// ToRankine converts a Celsius value to Rankine.
+func ToRankine(celsius float64) float64 {
+	return FromCelsius(celsius, Rankine)
+}
+
+// Round rounds value to precision decimals, half away from zero. A negative
+// precision rounds to tens, hundreds and so on.
+func Round(value float64, precision int) float64 {
+	if precision > MaxPrecision || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+	scale := math.Pow10(precision)
+	return math.Round(value*scale) / scale
+}
+
+// HeatIndex returns the apparent temperature in Celsius for a Celsius
+// reading and relative humidity (0-100), using the US National Weather
+// Service formula. Below 80°F the heat index is close to the reading and
+// the simpler Steadman approximation is used.
+func HeatIndex(celsius, humidity float64) float64 {
+	t := ToFahrenheit(celsius)
+	rh := humidity
+
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 < 80 {
+		return ToCelsius(hi, Fahrenheit)
+	}
+
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return ToCelsius(hi, Fahrenheit)
+}
+
+// WindChill returns the perceived temperature in Celsius for a Celsius
+// reading and wind speed in km/h, using the North American wind chill
+// index. Outside its range (above 10°C or below 4.8 km/h) wind chill is
+// not defined and the reading is returned unchanged.
+func WindChill(celsius, windKph float64) float64 {
+	if celsius > 10 || windKph < 4.8 {
+		return celsius
+	}
+	v := math.Pow(windKph, 0.16)
+	return 13.12 + 0.6215*celsius - 11.37*v + 0.3965*celsius*v
+}
+
+// DewPoint returns the dew point in Celsius for a Celsius reading and
+// relative humidity (0-100), using the Magnus formula. It returns NaN when
+// humidity is not positive.
+func DewPoint(celsius, humidity float64) float64 {
+	if humidity <= 0 {
+		return math.NaN()
+	}
+	const a, b = 17.62, 243.12
+	gamma := math.Log(math.Min(humidity, 100)/100) + a*celsius/(b+celsius)
+	return b * gamma / (a - gamma)
+}
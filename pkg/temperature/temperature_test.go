@@ -0,0 +1,179 @@
+package temperature
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+const tolerance = 1e-9
+
+// between generates quick arguments uniformly distributed in [min, max].
+func between(ranges ...[2]float64) *quick.Config {
+	return &quick.Config{
+		MaxCount: 5000,
+		Values: func(args []reflect.Value, r *rand.Rand) {
+			for i := range args {
+				min, max := ranges[i][0], ranges[i][1]
+				args[i] = reflect.ValueOf(min + r.Float64()*(max-min))
+			}
+		},
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(1, math.Abs(b))
+}
+
+func TestConvertKnownValues(t *testing.T) {
+	expected := []struct {
+		value    float64
+		from, to Unit
+		want     float64
+	}{
+		{0, Celsius, Kelvin, 273.15},
+		{100, Celsius, Fahrenheit, 212},
+		{-40, Celsius, Fahrenheit, -40},
+		{0, Kelvin, Rankine, 0},
+		{0, Celsius, Rankine, 491.67},
+		{32, Fahrenheit, Kelvin, 273.15},
+		{20, Celsius, Celsius, 20},
+	}
+
+	for _, e := range expected {
+		if got := Convert(e.value, e.from, e.to); !near(got, e.want) {
+			t.Errorf("Expected %v%s = %v%s, got %v", e.value, e.from, e.want, e.to, got)
+		}
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, from := range Units {
+		for _, to := range Units {
+			roundTrip := func(celsius float64) bool {
+				value := FromCelsius(celsius, from)
+				return near(Convert(Convert(value, from, to), to, from), value)
+			}
+			if err := quick.Check(roundTrip, between([2]float64{AbsoluteZero, 1000})); err != nil {
+				t.Errorf("%s -> %s: %v", from, to, err)
+			}
+		}
+	}
+}
+
+func TestConvertPreservesOrder(t *testing.T) {
+	for _, unit := range Units {
+		monotonic := func(a, b float64) bool {
+			if a > b {
+				a, b = b, a
+			}
+			return FromCelsius(a, unit) <= FromCelsius(b, unit)
+		}
+		if err := quick.Check(monotonic, between([2]float64{-300, 1000}, [2]float64{-300, 1000})); err != nil {
+			t.Errorf("%s: %v", unit, err)
+		}
+	}
+}
+
+func TestAbsoluteScalesAreNonNegative(t *testing.T) {
+	nonNegative := func(celsius float64) bool {
+		return ToKelvin(celsius) >= 0 && ToRankine(celsius) >= 0
+	}
+	if err := quick.Check(nonNegative, between([2]float64{AbsoluteZero, 1000})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	if unit, err := ParseUnit(" k "); err != nil || unit != Kelvin {
+		t.Errorf("Expected K, got %q (%v)", unit, err)
+	}
+	if _, err := ParseUnit("X"); err == nil {
+		t.Error("Expected error for an unknown unit")
+	}
+}
+
+func TestRound(t *testing.T) {
+	expected := []struct {
+		value     float64
+		precision int
+		want      float64
+	}{
+		{24.15, 1, 24.2},
+		{-24.15, 1, -24.2},
+		{297.3149, 2, 297.31},
+		{1234.5, -2, 1200},
+		{24.1, 0, 24},
+	}
+	for _, e := range expected {
+		if got := Round(e.value, e.precision); !near(got, e.want) {
+			t.Errorf("Expected Round(%v, %d) = %v, got %v", e.value, e.precision, e.want, got)
+		}
+	}
+
+	if got := Round(math.Pi, MaxPrecision+1); got != math.Pi {
+		t.Errorf("Expected precision above MaxPrecision to keep the value, got %v", got)
+	}
+
+	for precision := 0; precision <= 4; precision++ {
+		bounded := func(value float64) bool {
+			rounded := Round(value, precision)
+			return math.Abs(rounded-value) <= 0.5*math.Pow10(-precision)+tolerance &&
+				Round(rounded, precision) == rounded
+		}
+		if err := quick.Check(bounded, between([2]float64{-500, 500})); err != nil {
+			t.Errorf("precision %d: %v", precision, err)
+		}
+	}
+}
+
+func TestHeatIndex(t *testing.T) {
+	// NWS table: 96°F at 65% humidity feels like 121°F.
+	if got := ToFahrenheit(HeatIndex(ToCelsius(96, Fahrenheit), 65)); math.Abs(got-121) > 1 {
+		t.Errorf("Expected about 121°F, got %v", got)
+	}
+
+	hotter := func(celsius, humidity float64) bool {
+		return HeatIndex(celsius, humidity) >= celsius
+	}
+	if err := quick.Check(hotter, between([2]float64{32, 45}, [2]float64{40, 100})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	// Environment Canada table: -20°C at 30 km/h feels like -33°C.
+	if got := WindChill(-20, 30); math.Abs(got-(-33)) > 0.5 {
+		t.Errorf("Expected about -33°C, got %v", got)
+	}
+	if got := WindChill(20, 30); got != 20 {
+		t.Errorf("Expected the reading above 10°C, got %v", got)
+	}
+
+	colder := func(celsius, windKph float64) bool {
+		return WindChill(celsius, windKph) <= celsius
+	}
+	if err := quick.Check(colder, between([2]float64{-50, 10}, [2]float64{4.8, 120})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDewPoint(t *testing.T) {
+	// 25°C at 60% humidity has a dew point of about 16.7°C.
+	if got := DewPoint(25, 60); math.Abs(got-16.7) > 0.1 {
+		t.Errorf("Expected about 16.7°C, got %v", got)
+	}
+	if got := DewPoint(25, 0); !math.IsNaN(got) {
+		t.Errorf("Expected NaN without humidity, got %v", got)
+	}
+
+	bounded := func(celsius, humidity float64) bool {
+		dewPoint := DewPoint(celsius, humidity)
+		return dewPoint <= celsius+tolerance && near(DewPoint(celsius, 100), celsius)
+	}
+	if err := quick.Check(bounded, between([2]float64{-40, 50}, [2]float64{1, 100})); err != nil {
+		t.Error(err)
+	}
+}
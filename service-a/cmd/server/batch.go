@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/render"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-a/internal/service"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// BatchItem is the answer for one entry of a /cep/batch request. Index is
+// the position of the entry in the request.
+type BatchItem struct {
+	Index  int              `json:"index"`
+	Cep    string           `json:"cep"`
+	Status int              `json:"status"`
+	Result *CepV2Response   `json:"result,omitempty"`
+	Error  *problem.Problem `json:"error,omitempty"`
+}
+
+// cepBatchHandler answers a batch of CEPs, sent as a JSON array or as
+// NDJSON. JSON batches are answered with an array in request order, NDJSON
+// ones are streamed one line per entry as lookups complete.
+func cepBatchHandler(cepService service.CepService, concurrency, maxSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		carrier := propagation.HeaderCarrier(
+			r.Header,
+		)
+		ctx := r.Context()
+		ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+		tr := otel.Tracer("a-b-trace")
+		ctx, span := tr.Start(ctx, "get weather batch")
+		defer span.End()
+
+		fields, err := conditions.ParseFields(r.URL.Query().Get("fields"))
+		if err != nil {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+				WithDetail(err.Error()))
+			return
+		}
+
+		options, err := render.ParseOptions(r)
+		if err != nil {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+				WithDetail(err.Error()))
+			return
+		}
+		if options.Precision == nil {
+			options.Precision = cepV2Rendering().Precision
+		}
+
+		batch := newCepBatch(ctx, cepService, fields, concurrency)
+		defer func() {
+			span.SetAttributes(
+				attribute.Int("batch.size", batch.size),
+				attribute.Int("batch.unique", len(batch.lookups)),
+			)
+		}()
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == ndjsonContentType {
+			streamCepBatch(w, r, batch, options, maxSize)
+			return
+		}
+
+		var entries []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil || len(entries) == 0 || len(entries) > maxSize {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid batch").
+				WithDetail(fmt.Sprintf("expected a JSON array of 1 to %d CEPs", maxSize)))
+			return
+		}
+
+		go func() {
+			for index, entry := range entries {
+				batch.add(index, entry)
+			}
+			batch.close()
+		}()
+
+		items := make([]BatchItem, len(entries))
+		for item := range batch.items {
+			items[item.Index] = item
+		}
+		// Nobody reads the answer of a client gone.
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(renderBatch(items, options))
+	}
+}
+
+// streamCepBatch reads NDJSON entries while writing the answers of the
+// ones already looked up, flushing every line.
+func streamCepBatch(w http.ResponseWriter, r *http.Request, batch *cepBatch, options render.Options, maxSize int) {
+	controller := http.NewResponseController(w)
+	controller.EnableFullDuplex()
+
+	go func() {
+		defer batch.close()
+
+		scanner := bufio.NewScanner(r.Body)
+		index := 0
+		for scanner.Scan() {
+			entry := bytes.TrimSpace(scanner.Bytes())
+			if len(entry) == 0 {
+				continue
+			}
+			if index == maxSize {
+				batch.reject(index, "", problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid batch").
+					WithDetail(fmt.Sprintf("batches are limited to %d CEPs, the remaining entries were ignored", maxSize)))
+				return
+			}
+			batch.add(index, entry)
+			index++
+		}
+		if err := scanner.Err(); err != nil {
+			batch.reject(index, "", problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid batch").
+				WithDetail(err.Error()))
+		}
+	}()
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	for item := range batch.items {
+		line := renderBatch(item, options)
+		if _, err := w.Write(line); err != nil {
+			continue
+		}
+		controller.Flush()
+	}
+}
+
+// renderBatch encodes v, applying the rendering options of the request.
+func renderBatch(v any, options render.Options) []byte {
+	body, _ := json.Marshal(v)
+	if rendered, err := options.Rewrite(body); err == nil {
+		body = rendered
+	}
+	return append(body, '\n')
+}
+
+// cepLookup is the temperature lookup of a CEP, shared by every entry of
+// the batch asking for it.
+type cepLookup struct {
+	done chan struct{}
+	// span is linked from the span of every entry sharing the lookup.
+	span   trace.SpanContext
+	output *service.CepServiceOutput
+	err    error
+}
+
+// cepBatch looks up the entries of a batch, each distinct CEP once and at
+// most concurrency of them at a time, and answers every entry on items.
+// Entries are added by a single goroutine, which calls close once done.
+type cepBatch struct {
+	ctx        context.Context
+	cepService service.CepService
+	fields     conditions.Fields
+	slots      chan struct{}
+	lookups    map[string]*cepLookup
+	items      chan BatchItem
+	wg         sync.WaitGroup
+	size       int
+}
+
+func newCepBatch(ctx context.Context, cepService service.CepService, fields conditions.Fields, concurrency int) *cepBatch {
+	return &cepBatch{
+		ctx:        ctx,
+		cepService: cepService,
+		fields:     fields,
+		slots:      make(chan struct{}, max(concurrency, 1)),
+		lookups:    map[string]*cepLookup{},
+		items:      make(chan BatchItem),
+	}
+}
+
+// add answers the entry at index, either a CEP string or a /cep body, once
+// its lookup completes. Entries cancelled by the client are not answered.
+func (b *cepBatch) add(index int, entry []byte) {
+	b.size++
+
+	tr := otel.Tracer("a-b-trace")
+	_, span := tr.Start(b.ctx, "get weather batch item", trace.WithAttributes(attribute.Int("batch.index", index)))
+
+	cep, ok := batchCep(entry)
+	span.SetAttributes(attribute.String("cep", cep))
+	if !ok {
+		p := problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidZipcode, "invalid zipcode")
+		span.SetAttributes(attribute.Int("batch.status", p.Status))
+		span.End()
+		b.reject(index, cep, p)
+		return
+	}
+
+	lookup := b.lookup(cep)
+	span.AddLink(trace.Link{SpanContext: lookup.span})
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer span.End()
+		<-lookup.done
+		if errors.Is(lookup.err, context.Canceled) {
+			return
+		}
+
+		item := b.item(index, cep, lookup)
+		span.SetAttributes(attribute.Int("batch.status", item.Status))
+		if item.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, item.Error.Title)
+		}
+		b.items <- item
+	}()
+}
+
+// reject answers the entry at index with p, without looking it up.
+func (b *cepBatch) reject(index int, cep string, p *problem.Problem) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.items <- BatchItem{Index: index, Cep: cep, Status: p.Status, Error: p}
+	}()
+}
+
+// lookup returns the lookup of cep, starting it when it is the first entry
+// asking for it. It blocks while concurrency lookups are in flight.
+func (b *cepBatch) lookup(cep string) *cepLookup {
+	if lookup, ok := b.lookups[cep]; ok {
+		return lookup
+	}
+
+	// The lookup span starts before waiting for a slot, so the entries can
+	// link to it right away.
+	tr := otel.Tracer("a-b-trace")
+	ctx, span := tr.Start(b.ctx, "get weather batch lookup", trace.WithAttributes(attribute.String("cep", cep)))
+
+	lookup := &cepLookup{done: make(chan struct{}), span: span.SpanContext()}
+	b.lookups[cep] = lookup
+
+	select {
+	case b.slots <- struct{}{}:
+	case <-b.ctx.Done():
+		lookup.err = b.ctx.Err()
+		span.End()
+		close(lookup.done)
+		return lookup
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer func() { <-b.slots }()
+		defer close(lookup.done)
+		defer span.End()
+
+		lookup.output, lookup.err = b.cepService.GetTemperature(ctx, cep)
+		if lookup.err != nil && !errors.Is(lookup.err, context.Canceled) {
+			span.RecordError(lookup.err)
+			if p := errorProblem(lookup.err); p.Status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, p.Title)
+			}
+		}
+	}()
+	return lookup
+}
+
+func (b *cepBatch) item(index int, cep string, lookup *cepLookup) BatchItem {
+	if lookup.err != nil {
+		p := errorProblem(lookup.err)
+		if p.Status >= http.StatusInternalServerError {
+			log.Println("Error getting temperature: ", lookup.err)
+		}
+		return BatchItem{Index: index, Cep: cep, Status: p.Status, Error: p}
+	}
+
+	return BatchItem{
+		Index:  index,
+		Cep:    cep,
+		Status: http.StatusOK,
+		Result: cepV2Response(cep, lookup.output, b.fields).(*CepV2Response),
+	}
+}
+
+// close closes items once every entry was answered.
+func (b *cepBatch) close() {
+	b.wg.Wait()
+	close(b.items)
+}
+
+// batchCep reads a batch entry, either a CEP string or a /cep body.
+func batchCep(entry []byte) (string, bool) {
+	var cep string
+	if err := json.Unmarshal(entry, &cep); err != nil {
+		input := &Input{}
+		if err := json.Unmarshal(entry, input); err != nil {
+			return "", false
+		}
+		cep = input.Cep
+	}
+	return cep, validCEP(cep)
+}
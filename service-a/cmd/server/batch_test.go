@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-a/internal/service"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// countingCepService records the lookups made and how many ran at once.
+type countingCepService struct {
+	delay time.Duration
+
+	mu        sync.Mutex
+	calls     map[string]int
+	active    int
+	maxActive int
+}
+
+func (c *countingCepService) GetTemperature(ctx context.Context, cep string) (*service.CepServiceOutput, error) {
+	c.mu.Lock()
+	if c.calls == nil {
+		c.calls = map[string]int{}
+	}
+	c.calls[cep]++
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+	c.mu.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+
+	if cep == "00000000" {
+		return nil, service.CepNotFoundError
+	}
+	return &service.CepServiceOutput{City: "Rio de Janeiro", Temp_C: 24.123, Temp_F: 75.4214, Temp_K: 297.273}, nil
+}
+
+func (c *countingCepService) Name() string {
+	return "counting"
+}
+
+func TestCepBatchHandler(t *testing.T) {
+	cepService := &countingCepService{delay: 10 * time.Millisecond}
+	handler := cepBatchHandler(cepService, 2, 10)
+
+	body := `["20561250","01001000",{"cep":"20561250"},"123","00000000","80010000","90010000"]`
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/cep/batch?units=C", strings.NewReader(body)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+
+	var items []BatchItem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{200, 200, 200, 422, 404, 200, 200}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %d items, got %d", len(expected), len(items))
+	}
+	for i, status := range expected {
+		if items[i].Index != i || items[i].Status != status {
+			t.Errorf("Expected item %d with status %d, got %+v", i, status, items[i])
+		}
+	}
+	if items[0].Result == nil || items[0].Result.Temp_C != 24.1 || items[0].Result.Temp_K != 0 {
+		t.Errorf("Expected Celsius rounded to one decimal, got %+v", items[0].Result)
+	}
+	if items[4].Error == nil || items[4].Error.Code != "zipcode_not_found" {
+		t.Errorf("Expected zipcode_not_found, got %+v", items[4].Error)
+	}
+
+	if cepService.calls["20561250"] != 1 || len(cepService.calls) != 5 {
+		t.Errorf("Expected each distinct CEP looked up once, got %v", cepService.calls)
+	}
+	if cepService.maxActive > 2 {
+		t.Errorf("Expected at most 2 concurrent lookups, got %d", cepService.maxActive)
+	}
+}
+
+func TestCepBatchHandlerNDJSON(t *testing.T) {
+	handler := cepBatchHandler(&countingCepService{}, 4, 2)
+
+	request := httptest.NewRequest(http.MethodPost, "/cep/batch", strings.NewReader("\"20561250\"\n\n{\"cep\":\"01001000\"}\n\"80010000\"\n"))
+	request.Header.Set("Content-Type", ndjsonContentType)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != ndjsonContentType {
+		t.Errorf("Expected %s, got %s", ndjsonContentType, contentType)
+	}
+
+	statuses := map[int]int{}
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var item BatchItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		statuses[item.Index] = item.Status
+	}
+
+	expected := map[int]int{0: 200, 1: 200, 2: 422}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, statuses)
+	}
+	for index, status := range expected {
+		if statuses[index] != status {
+			t.Errorf("Expected item %d with status %d, got %d", index, status, statuses[index])
+		}
+	}
+}
+
+func TestCepBatchHandlerInvalidBody(t *testing.T) {
+	handler := cepBatchHandler(&countingCepService{}, 1, 2)
+
+	for _, body := range []string{`{"cep":"20561250"}`, `[]`, `["20561250","01001000","80010000"]`} {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, "/cep/batch", strings.NewReader(body)))
+		if recorder.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", body, recorder.Code)
+		}
+	}
+}
+
+// blockingCepService answers once the lookup is cancelled.
+type blockingCepService struct{}
+
+func (blockingCepService) GetTemperature(ctx context.Context, cep string) (*service.CepServiceOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingCepService) Name() string {
+	return "blocking"
+}
+
+func TestCepBatchHandlerDeadline(t *testing.T) {
+	handler := cepBatchHandler(blockingCepService{}, 1, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/cep/batch", strings.NewReader(`["20561250","01001000"]`)).WithContext(ctx))
+
+	var items []BatchItem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if item.Status != http.StatusGatewayTimeout || item.Error.Code != "upstream_timeout" {
+			t.Errorf("Expected 504 upstream_timeout, got %+v", item)
+		}
+	}
+}
+
+func TestCepBatchHandlerCancelled(t *testing.T) {
+	handler := cepBatchHandler(blockingCepService{}, 1, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/cep/batch", strings.NewReader(`["20561250","01001000"]`)).WithContext(ctx))
+
+	if recorder.Body.Len() != 0 {
+		t.Errorf("Expected no answer for a client gone, got %s", recorder.Body.String())
+	}
+}
+
+func TestCepBatchHandlerSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	handler := cepBatchHandler(&countingCepService{}, 2, 10)
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cep/batch", strings.NewReader(`["20561250","20561250","01001000"]`)))
+
+	lookups := map[trace.SpanID]bool{}
+	var items []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "get weather batch lookup":
+			lookups[span.SpanContext().SpanID()] = true
+		case "get weather batch item":
+			items = append(items, span)
+		}
+	}
+
+	if len(lookups) != 2 || len(items) != 3 {
+		t.Fatalf("Expected 2 lookup spans and 3 item spans, got %d and %d", len(lookups), len(items))
+	}
+	for _, item := range items {
+		if links := item.Links(); len(links) != 1 || !lookups[links[0].SpanContext.SpanID()] {
+			t.Errorf("Expected item span linked to its lookup, got %+v", links)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,165 +14,438 @@ import (
 	"os/signal"
 	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/circuit"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/health"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/render"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/retry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-a/internal/service"
 	"github.com/spf13/viper"
 
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
 type Input struct {
 	Cep string `json:"cep"`
 }
 
+type ForecastInput struct {
+	Cep    string `json:"cep"`
+	Days   int    `json:"days"`
+	Hourly bool   `json:"hourly"`
+}
+
+// Forecast length bounds, matching service-b.
+const (
+	defaultForecastDays = 3
+	maxForecastDays     = 14
+)
+
 type ServiceBResponse struct {
 	City   string `json:"city"`
 	Temp_C string `json:"temp_C"`
 	Temp_F string `json:"temp_F"`
 	Temp_K string `json:"temp_K"`
+	// Extended current conditions, only set for the groups requested with
+	// the fields query parameter.
+	conditions.Extended
 }
 
-func init() {
-	viper.AutomaticEnv()
+// CepV2Response is the /v2/cep answer: numeric temperatures and the
+// metadata of the reading.
+type CepV2Response struct {
+	Cep        string     `json:"cep"`
+	City       string     `json:"city"`
+	Uf         string     `json:"uf,omitempty"`
+	Temp_C     float64    `json:"temp_C"`
+	Temp_F     float64    `json:"temp_F"`
+	Temp_K     float64    `json:"temp_K"`
+	Source     string     `json:"source,omitempty"`
+	ObservedAt *time.Time `json:"observed_at,omitempty"`
+	Stale      bool       `json:"stale,omitempty"`
+	conditions.Extended
 }
 
-func initProvider() (func(context.Context) error, error) {
-	traceExporter, err := zipkin.New("http://zipkin:9411/api/v2/spans")
-	if err != nil {
-		return nil, err
+// cepResponse builds the JSON answer of a cep route.
+type cepResponse func(cep string, output *service.CepServiceOutput, fields conditions.Fields) any
+
+// legacyCepResponse is the /cep answer, with temperatures formatted as
+// strings. Kept byte for byte for existing consumers.
+func legacyCepResponse(cep string, output *service.CepServiceOutput, fields conditions.Fields) any {
+	return &ServiceBResponse{
+		City:     output.City,
+		Temp_C:   fmt.Sprintf("%f", output.Temp_C),
+		Temp_K:   fmt.Sprintf("%f", output.Temp_K),
+		Temp_F:   fmt.Sprintf("%f", output.Temp_F),
+		Extended: output.Extended.Filter(fields),
 	}
+}
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
-	batcher := trace.NewBatchSpanProcessor(traceExporter)
+func cepV2Response(cep string, output *service.CepServiceOutput, fields conditions.Fields) any {
+	response := &CepV2Response{
+		Cep:      cep,
+		City:     output.City,
+		Uf:       output.Uf,
+		Temp_C:   output.Temp_C,
+		Temp_F:   output.Temp_F,
+		Temp_K:   output.Temp_K,
+		Source:   output.Source,
+		Stale:    output.Stale,
+		Extended: output.Extended.Filter(fields),
+	}
+	if !output.ObservedAt.IsZero() {
+		response.ObservedAt = &output.ObservedAt
+	}
+	return response
+}
 
-	tp := trace.NewTracerProvider(
-		trace.WithSampler(trace.AlwaysSample()),
-		trace.WithSpanProcessor(batcher),
-		trace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String("service-a"),
-			),
-		),
-	)
-	otel.SetTracerProvider(tp)
+// cepV2Rendering rounds /v2/cep temperatures to one decimal unless the
+// client asks for another precision.
+func cepV2Rendering() render.Options {
+	precision := temperature.DefaultPrecision
+	return render.Options{Precision: &precision}
+}
+
+func init() {
+	viper.AutomaticEnv()
+	viper.SetDefault("SERVICE_B_URL", service.DefaultBServiceBaseURL)
+	viper.SetDefault("SERVICE_B_TIMEOUT", service.DefaultBServiceTimeout)
+	viper.SetDefault("SERVICE_B_USER_AGENT", service.DefaultBServiceUserAgent)
+	viper.SetDefault("SHUTDOWN_GRACE_PERIOD", 10*time.Second)
+	viper.SetDefault("BATCH_CONCURRENCY", 10)
+	viper.SetDefault("BATCH_MAX_SIZE", 1000)
+	viper.SetDefault("CIRCUIT_BREAKER_ENABLED", true)
+	viper.SetDefault("CIRCUIT_BREAKER_FAILURE_THRESHOLD", circuit.DefaultFailureThreshold)
+	viper.SetDefault("CIRCUIT_BREAKER_OPEN_TIMEOUT", circuit.DefaultOpenTimeout)
+	viper.SetDefault("CIRCUIT_BREAKER_HALF_OPEN_PROBES", circuit.DefaultHalfOpenProbes)
+	viper.SetDefault("HEALTH_DETAILED", false)
+	// Longer than the checks of service-b, which its readiness waits on.
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", health.DefaultTimeout+time.Second)
+	viper.SetDefault("RETRY_ENABLED", true)
+	viper.SetDefault("RETRY_MAX_ATTEMPTS", retry.DefaultMaxAttempts)
+	viper.SetDefault("RETRY_BASE_DELAY", retry.DefaultBaseDelay)
+	viper.SetDefault("RETRY_MAX_DELAY", retry.DefaultMaxDelay)
+	viper.SetDefault("RETRY_BUDGET_RATIO", 0.2)
+	viper.SetDefault("RETRY_BUDGET_BURST", 10)
+}
 
-	return tp.Shutdown, nil
+func initProvider(ctx context.Context) (func(context.Context) error, error) {
+	return telemetry.InitTracerProvider(ctx, telemetry.Config{
+		ServiceName: "service-a",
+		Exporter:    viper.GetString("TRACES_EXPORTER"),
+		Endpoint:    viper.GetString("TRACES_EXPORTER_ENDPOINT"),
+		Headers:     telemetry.ParseHeaders(viper.GetString("TRACES_EXPORTER_HEADERS")),
+		Insecure:    viper.GetBool("TRACES_EXPORTER_INSECURE"),
+	})
 }
 
 func main() {
 	webServerPort := viper.GetString("HTTP_PORT")
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
-
 	ctx, cancel := signal.NotifyContext(
 		context.Background(),
 		os.Interrupt,
+		syscall.SIGTERM,
 	)
 	defer cancel()
 
-	_, err := initProvider()
+	shutdownTracer, err := initProvider(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go func() {
-		r := chi.NewRouter()
-		r.Use(middleware.Logger)
-		r.Use(middleware.Recoverer)
-		r.Post("/cep", cepHandler)
+	metricsHandler, shutdownMeter, err := telemetry.InitMeterProvider("service-a")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cepService, err := cepServiceGateway(viper.GetString("CEP_SERVICE"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(telemetry.HTTPMetrics)
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+	r.With(render.Middleware(render.Options{})).Post("/cep", cepHandler(cepService, legacyCepResponse))
+	r.With(render.Middleware(cepV2Rendering())).Post("/v2/cep", cepHandler(cepService, cepV2Response))
+	r.Post("/cep/batch", cepBatchHandler(cepService, viper.GetInt("BATCH_CONCURRENCY"), viper.GetInt("BATCH_MAX_SIZE")))
+	if forecastService, ok := cepService.(service.ForecastService); ok {
+		r.With(render.Middleware(render.Options{})).Post("/forecast", forecastHandler(forecastService))
+	}
+	r.Handle("/metrics", metricsHandler)
+	r.Get("/healthz", health.Live)
+	r.Get("/readyz", readinessChecker(cepService).Handler(viper.GetBool("HEALTH_DETAILED")))
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", webServerPort),
+		Handler: r,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
 		fmt.Println("Server running at port:", webServerPort)
-		port := fmt.Sprintf(":%s", webServerPort)
-		if err := http.ListenAndServe(port, r); err != nil {
-			log.Fatal("Error initializing server, ", err)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
 	}()
 
 	select {
-	case <-sigCh:
-		log.Println("Shutting down gracefully, CTRL+C pressed")
 	case <-ctx.Done():
-		log.Println("Shutting down because operation is finished")
+		log.Println("Shutting down gracefully, signal received")
+	case err := <-serverErr:
+		log.Println("Error running server, shutting down: ", err)
 	}
 
-	_, shutdownCancel := context.WithTimeout(
+	shutdownCtx, shutdownCancel := context.WithTimeout(
 		context.Background(),
-		10*time.Second,
+		viper.GetDuration("SHUTDOWN_GRACE_PERIOD"),
 	)
 	defer shutdownCancel()
+
+	// In-flight requests finish before the providers flush their last
+	// spans and metrics.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error draining connections: ", err)
+	}
+	if err := shutdownTracer(shutdownCtx); err != nil {
+		log.Println("Error shutting down tracer provider: ", err)
+	}
+	if err := shutdownMeter(shutdownCtx); err != nil {
+		log.Println("Error shutting down meter provider: ", err)
+	}
 }
 
-func cepHandler(w http.ResponseWriter, r *http.Request) {
-	cepService := cepServiceGateway(viper.GetString("CEP_SERVICE"))
-	carrier := propagation.HeaderCarrier(
-		r.Header,
-	)
-	ctx := r.Context()
-	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+func cepHandler(cepService service.CepService, response cepResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		carrier := propagation.HeaderCarrier(
+			r.Header,
+		)
+		ctx := r.Context()
+		ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
 
-	tr := otel.Tracer("a-b-trace")
-	ctx, span := tr.Start(ctx, "get weather")
-	defer span.End()
+		tr := otel.Tracer("a-b-trace")
+		ctx, span := tr.Start(ctx, "get weather")
+		defer span.End()
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+		if r.Method != http.MethodPost {
+			problem.Write(ctx, w, r, problem.New(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "method not allowed"))
+			return
+		}
 
-	parsedCep, err := parseCep(r.Body)
-	if err != nil {
-		http.Error(w, "invalid zipcode", http.StatusUnprocessableEntity)
-		return
+		parsedCep, err := parseCep(r.Body)
+		if err != nil {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidZipcode, "invalid zipcode"))
+			return
+		}
+
+		fields, err := conditions.ParseFields(r.URL.Query().Get("fields"))
+		if err != nil {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+				WithDetail(err.Error()))
+			return
+		}
+
+		output, err := cepService.GetTemperature(
+			ctx,
+			parsedCep,
+		)
+		if err != nil {
+			p := errorProblem(err)
+			if p.Status >= http.StatusInternalServerError {
+				log.Println("Error getting temperature: ", err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, p.Title)
+			}
+			problem.Write(ctx, w, r, p)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response(parsedCep, output, fields))
 	}
+}
 
-	output, err := cepService.GetTemperature(
-		ctx,
-		parsedCep,
-	)
-	if err != nil {
-		if err == service.InvalidCepError {
-			http.Error(w, "invalid zipcode", http.StatusUnprocessableEntity)
+func forecastHandler(forecastService service.ForecastService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		carrier := propagation.HeaderCarrier(
+			r.Header,
+		)
+		ctx := r.Context()
+		ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+		tr := otel.Tracer("a-b-trace")
+		ctx, span := tr.Start(ctx, "get forecast")
+		defer span.End()
+
+		input := &ForecastInput{}
+		if err := json.NewDecoder(r.Body).Decode(input); err != nil || !validCEP(input.Cep) {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidZipcode, "invalid zipcode"))
+			return
+		}
+		if input.Days == 0 {
+			input.Days = defaultForecastDays
+		}
+		if input.Days < 1 || input.Days > maxForecastDays {
+			problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+				WithDetail(fmt.Sprintf("days must be between 1 and %d", maxForecastDays)))
 			return
 		}
 
-		if err == service.CepNotFoundError {
-			http.Error(w, "can not find zipcode", http.StatusNotFound)
+		output, err := forecastService.GetForecast(ctx, service.ForecastInput{
+			Cep:    input.Cep,
+			Days:   input.Days,
+			Hourly: input.Hourly,
+		})
+		if err != nil {
+			p := errorProblem(err)
+			if p.Status >= http.StatusInternalServerError {
+				log.Println("Error getting forecast: ", err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, p.Title)
+			}
+			problem.Write(ctx, w, r, p)
 			return
 		}
 
-		http.Error(w, "invalid zipcode", http.StatusUnprocessableEntity)
-		return
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(output)
+	}
+}
+
+// errorProblem maps the CepService errors to the problem returned to
+// clients, keeping the details reported by service-b.
+func errorProblem(err error) *problem.Problem {
+	var p *problem.Problem
+	switch {
+	case errors.Is(err, service.InvalidCepError):
+		p = problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidZipcode, "invalid zipcode")
+	case errors.Is(err, service.CepNotFoundError):
+		p = problem.New(http.StatusNotFound, problem.CodeZipcodeNotFound, "can not find zipcode")
+	case errors.Is(err, service.UpstreamTimeoutError), errors.Is(err, context.DeadlineExceeded):
+		p = problem.New(http.StatusGatewayTimeout, problem.CodeUpstreamTimeout, "weather service timed out")
+	case errors.Is(err, service.UpstreamRateLimitedError):
+		p = problem.New(http.StatusServiceUnavailable, problem.CodeUpstreamRateLimited, "weather service temporarily unavailable")
+	case errors.Is(err, service.UpstreamAuthError):
+		p = problem.New(http.StatusBadGateway, problem.CodeUpstreamAuth, "weather service rejected credentials")
+	case errors.Is(err, service.UpstreamBadPayloadError):
+		p = problem.New(http.StatusBadGateway, problem.CodeUpstreamBadPayload, "weather service returned an invalid payload")
+	case errors.Is(err, service.UpstreamUnavailableError):
+		p = problem.New(http.StatusBadGateway, problem.CodeUpstreamUnavailable, "weather service unavailable")
+	case errors.Is(err, service.CircuitOpenError):
+		p = problem.New(http.StatusServiceUnavailable, problem.CodeCircuitOpen, "weather service circuit open")
+	default:
+		return problem.New(http.StatusInternalServerError, problem.CodeInternal, "internal error")
+	}
 
+	var serviceBErr *service.ServiceBError
+	if errors.As(err, &serviceBErr) {
+		p.WithUpstream(serviceBErr.Problem.AsUpstream("service-b"))
+	} else if p.Status >= http.StatusInternalServerError {
+		p.WithDetail(err.Error())
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(&ServiceBResponse{
-		City:   output.City,
-		Temp_C: fmt.Sprintf("%f", output.Temp_C),
-		Temp_K: fmt.Sprintf("%f", output.Temp_K),
-		Temp_F: fmt.Sprintf("%f", output.Temp_F),
-	})
+	return p
 }
 
-func cepServiceGateway(cepService string) service.CepService {
+// readinessChecker checks the service cepService depends on, if any.
+func readinessChecker(cepService service.CepService) *health.Checker {
+	checker := health.NewChecker(viper.GetDuration("HEALTH_CHECK_TIMEOUT"))
+	if pinger, ok := cepService.(service.Pinger); ok {
+		checker.Critical("service-b", pinger.Ping)
+	}
+	return checker
+}
+
+func cepServiceGateway(cepService string) (service.CepService, error) {
 	switch strings.ToUpper(cepService) {
 	case "MEMORY":
-		return service.NewMemoryCepService()
+		fixture, err := service.LoadMemoryFixture(viper.GetString("MEMORY_FIXTURE"))
+		if err != nil {
+			return nil, err
+		}
+
+		// A zero seed picks a different one on every start.
+		seed := viper.GetInt64("MEMORY_SEED")
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		log.Println("Memory cep service seed: ", seed)
+		return service.NewMemoryCepService(fixture, seed), nil
 	default:
-		return service.NewBService()
+		tlsConfig, err := serviceBTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		var breaker *circuit.Breaker
+		if viper.GetBool("CIRCUIT_BREAKER_ENABLED") {
+			breaker = circuit.NewBreaker("service-b", circuit.Options{
+				FailureThreshold: viper.GetInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD"),
+				OpenTimeout:      viper.GetDuration("CIRCUIT_BREAKER_OPEN_TIMEOUT"),
+				HalfOpenProbes:   viper.GetInt("CIRCUIT_BREAKER_HALF_OPEN_PROBES"),
+			})
+		}
+
+		var retryOptions *retry.Options
+		if viper.GetBool("RETRY_ENABLED") {
+			retryOptions = &retry.Options{
+				MaxAttempts: viper.GetInt("RETRY_MAX_ATTEMPTS"),
+				BaseDelay:   viper.GetDuration("RETRY_BASE_DELAY"),
+				MaxDelay:    viper.GetDuration("RETRY_MAX_DELAY"),
+				Budget:      retry.NewBudget(viper.GetFloat64("RETRY_BUDGET_RATIO"), viper.GetInt("RETRY_BUDGET_BURST")),
+			}
+		}
+
+		return service.NewBService(service.BServiceOptions{
+			BaseURL:   viper.GetString("SERVICE_B_URL"),
+			Timeout:   viper.GetDuration("SERVICE_B_TIMEOUT"),
+			TLSConfig: tlsConfig,
+			UserAgent: viper.GetString("SERVICE_B_USER_AGENT"),
+			Breaker:   breaker,
+			Retry:     retryOptions,
+		}), nil
+	}
+}
+
+func serviceBTLSConfig() (*tls.Config, error) {
+	caFile := viper.GetString("SERVICE_B_CA_FILE")
+	insecure := viper.GetBool("SERVICE_B_TLS_INSECURE")
+	if caFile == "" && !insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading SERVICE_B_CA_FILE: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
 	}
+
+	return tlsConfig, nil
 }
 
 func parseCep(body io.ReadCloser) (string, error) {
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/render"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-a/internal/service"
+)
+
+type fakeCepService struct {
+	output *service.CepServiceOutput
+}
+
+func (f *fakeCepService) GetTemperature(ctx context.Context, cep string) (*service.CepServiceOutput, error) {
+	return f.output, nil
+}
+
+func (f *fakeCepService) Name() string {
+	return "fake"
+}
+
+func TestCepHandlers(t *testing.T) {
+	cepService := &fakeCepService{output: &service.CepServiceOutput{
+		City:       "Rio de Janeiro",
+		Uf:         "RJ",
+		Temp_C:     28.46,
+		Temp_F:     83.228,
+		Temp_K:     301.61,
+		Source:     "weatherapi",
+		ObservedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}}
+
+	expected := map[string]string{
+		"/cep":    `{"city":"Rio de Janeiro","temp_C":"28.460000","temp_F":"83.228000","temp_K":"301.610000"}`,
+		"/v2/cep": `{"cep":"20561250","city":"Rio de Janeiro","uf":"RJ","temp_C":28.5,"temp_F":83.2,"temp_K":301.6,"source":"weatherapi","observed_at":"2024-06-01T12:00:00Z"}`,
+	}
+	handlers := map[string]http.HandlerFunc{
+		"/cep":    cepHandler(cepService, legacyCepResponse),
+		"/v2/cep": render.Middleware(cepV2Rendering())(cepHandler(cepService, cepV2Response)).ServeHTTP,
+	}
+
+	for route, body := range expected {
+		recorder := httptest.NewRecorder()
+		handlers[route](recorder, httptest.NewRequest(http.MethodPost, route, strings.NewReader(`{"cep":"20561250"}`)))
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", route, recorder.Code)
+		}
+		if got := strings.TrimSpace(recorder.Body.String()); got != body {
+			t.Errorf("%s: expected %s, got %s", route, body, got)
+		}
+	}
+}
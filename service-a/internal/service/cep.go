@@ -3,6 +3,9 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
 )
 
 type CepService interface {
@@ -10,15 +13,39 @@ type CepService interface {
 	Name() string
 }
 
+// Pinger is implemented by the CepServices depending on a remote service,
+// checked by the readiness endpoint.
+type Pinger interface {
+	Ping(context.Context) error
+}
+
+// CepServiceOutput is named as in the answers of service-b, which it is
+// decoded from.
 type CepServiceOutput struct {
-	Cep    string
-	City   string
-	Temp_C float64
-	Temp_K float64
-	Temp_F float64
+	Cep    string  `json:"cep"`
+	City   string  `json:"city"`
+	Temp_C float64 `json:"temp_C"`
+	Temp_K float64 `json:"temp_K"`
+	Temp_F float64 `json:"temp_F"`
+	Uf     string  `json:"uf"`
+	// Source names the weather provider that measured the temperature.
+	Source     string    `json:"source"`
+	ObservedAt time.Time `json:"observed_at"`
+	Stale      bool      `json:"stale"`
+	// Extended current conditions, when the service reports them.
+	conditions.Extended
 }
 
 var (
 	CepNotFoundError = errors.New("Cep Not Found")
 	InvalidCepError  = errors.New("Invalid Cep")
+
+	UpstreamUnavailableError = errors.New("Upstream Unavailable")
+	UpstreamTimeoutError     = errors.New("Upstream Timeout")
+	UpstreamAuthError        = errors.New("Upstream Authentication Failed")
+	UpstreamRateLimitedError = errors.New("Upstream Rate Limited")
+	UpstreamBadPayloadError  = errors.New("Upstream Bad Payload")
+	// CircuitOpenError is returned without calling an upstream whose
+	// circuit breaker is open.
+	CircuitOpenError = errors.New("Upstream Circuit Open")
 )
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// ForecastService is implemented by the CepServices able to forecast the
+// weather of a CEP.
+type ForecastService interface {
+	GetForecast(context.Context, ForecastInput) (*ForecastOutput, error)
+}
+
+type ForecastInput struct {
+	Cep    string
+	Days   int
+	Hourly bool
+}
+
+type ForecastOutput struct {
+	City      string        `json:"city"`
+	Latitude  *float64      `json:"latitude,omitempty"`
+	Longitude *float64      `json:"longitude,omitempty"`
+	Days      []ForecastDay `json:"days"`
+}
+
+type ForecastDay struct {
+	Date          string         `json:"date"`
+	MinTemp_C     float64        `json:"min_temp_C"`
+	MinTemp_F     float64        `json:"min_temp_F"`
+	MinTemp_K     float64        `json:"min_temp_K"`
+	MaxTemp_C     float64        `json:"max_temp_C"`
+	MaxTemp_F     float64        `json:"max_temp_F"`
+	MaxTemp_K     float64        `json:"max_temp_K"`
+	Condition     string         `json:"condition"`
+	ConditionCode int            `json:"condition_code"`
+	ChanceOfRain  int            `json:"chance_of_rain"`
+	Hourly        []ForecastHour `json:"hourly,omitempty"`
+}
+
+type ForecastHour struct {
+	Time          time.Time `json:"time"`
+	Temp_C        float64   `json:"temp_C"`
+	Temp_F        float64   `json:"temp_F"`
+	Temp_K        float64   `json:"temp_K"`
+	Condition     string    `json:"condition"`
+	ConditionCode int       `json:"condition_code"`
+	ChanceOfRain  int       `json:"chance_of_rain"`
+}
@@ -2,16 +2,162 @@ package service
 
 import (
 	"context"
-	"math"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
 	"go.opentelemetry.io/otel"
 )
 
-type MemoryCepService struct{}
+// memoryFixture is the embedded fixture, in the format read by
+// ReadMemoryFixture.
+//
+//go:embed memory_fixture.json
+var memoryFixture string
 
-func NewMemoryCepService() *MemoryCepService {
-	return &MemoryCepService{}
+// MemoryFallbackCep is the fixture entry answering the CEPs without an entry
+// of their own. CEPs are not found when the fixture has none.
+const MemoryFallbackCep = "*"
+
+// memoryErrors are the errors a scenario can fail with, by name.
+var memoryErrors = map[string]error{
+	"not_found":    CepNotFoundError,
+	"invalid":      InvalidCepError,
+	"unavailable":  UpstreamUnavailableError,
+	"timeout":      UpstreamTimeoutError,
+	"rate_limited": UpstreamRateLimitedError,
+	"auth":         UpstreamAuthError,
+	"bad_payload":  UpstreamBadPayloadError,
+	"circuit_open": CircuitOpenError,
+}
+
+// MemoryFixture maps CEPs to the city and temperature MemoryCepService
+// answers for them.
+type MemoryFixture struct {
+	Ceps map[string]MemoryCep `json:"ceps"`
+	// Scenario applies to the CEPs without a scenario of their own.
+	Scenario MemoryScenario `json:"scenario"`
+}
+
+type MemoryCep struct {
+	City   string  `json:"city"`
+	Uf     string  `json:"uf"`
+	Temp_C float64 `json:"temp_C"`
+	// Scenario replaces the fixture scenario for this CEP.
+	Scenario *MemoryScenario `json:"scenario,omitempty"`
+}
+
+// MemoryScenario scripts how lookups behave, to reproduce slow or failing
+// upstreams locally.
+type MemoryScenario struct {
+	// Latency delays every lookup, plus a random delay up to Jitter.
+	Latency memoryDuration `json:"latency"`
+	Jitter  memoryDuration `json:"jitter"`
+	// ErrorRate is the share of lookups failing, from 0 to 1. Lookups fail
+	// with Error, or unavailable when unset.
+	ErrorRate float64 `json:"error_rate"`
+	// Error names the error lookups fail with, e.g. timeout. Every lookup
+	// fails when set without ErrorRate.
+	Error string `json:"error"`
+}
+
+// memoryDuration reads durations written as strings, e.g. "150ms".
+type memoryDuration time.Duration
+
+func (d *memoryDuration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = memoryDuration(duration)
+	return nil
+}
+
+func (s MemoryScenario) validate() error {
+	if s.Latency < 0 || s.Jitter < 0 {
+		return errors.New("negative latency")
+	}
+	if s.ErrorRate < 0 || s.ErrorRate > 1 {
+		return fmt.Errorf("error rate %v out of [0, 1]", s.ErrorRate)
+	}
+	if _, ok := memoryErrors[s.Error]; s.Error != "" && !ok {
+		names := make([]string, 0, len(memoryErrors))
+		for name := range memoryErrors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown error %q, expected one of %s", s.Error, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// ReadMemoryFixture reads a JSON fixture.
+func ReadMemoryFixture(r io.Reader) (*MemoryFixture, error) {
+	fixture := &MemoryFixture{}
+	if err := json.NewDecoder(r).Decode(fixture); err != nil {
+		return nil, fmt.Errorf("reading memory fixture: %w", err)
+	}
+
+	if err := fixture.Scenario.validate(); err != nil {
+		return nil, fmt.Errorf("memory fixture scenario: %w", err)
+	}
+	for cep, entry := range fixture.Ceps {
+		if entry.Scenario == nil {
+			continue
+		}
+		if err := entry.Scenario.validate(); err != nil {
+			return nil, fmt.Errorf("memory fixture scenario of %s: %w", cep, err)
+		}
+	}
+	return fixture, nil
+}
+
+// LoadMemoryFixture reads the fixture stored at path, or the embedded one
+// when path is empty.
+func LoadMemoryFixture(path string) (*MemoryFixture, error) {
+	if path == "" {
+		return ReadMemoryFixture(strings.NewReader(memoryFixture))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadMemoryFixture(f)
+}
+
+// MemoryCepService answers from a fixture, without calling service-b. Its
+// random draws come from a seeded generator, so a scenario replays the same
+// way for the same seed and sequence of lookups.
+type MemoryCepService struct {
+	fixture *MemoryFixture
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewMemoryCepService returns a MemoryCepService over fixture, the embedded
+// one when nil, drawing from seed.
+func NewMemoryCepService(fixture *MemoryFixture, seed int64) *MemoryCepService {
+	if fixture == nil {
+		fixture, _ = LoadMemoryFixture("")
+	}
+	return &MemoryCepService{fixture: fixture, rand: rand.New(rand.NewSource(seed))}
 }
 
 func (s *MemoryCepService) Name() string {
@@ -27,28 +173,61 @@ func (s *MemoryCepService) GetTemperature(ctx context.Context, cep string) (*Cep
 		return nil, InvalidCepError
 	}
 
-	if cep == "00000000" {
-		return nil, CepNotFoundError
+	entry, ok := s.fixture.Ceps[cep]
+	if !ok {
+		entry, ok = s.fixture.Ceps[MemoryFallbackCep]
 	}
 
-	min := 5.0
-	max := 35.0
-	random := min + rand.Float64()*(max-min)
-	temperature := math.Round(random)
+	scenario := s.fixture.Scenario
+	if entry.Scenario != nil {
+		scenario = *entry.Scenario
+	}
+
+	delay, fail := s.draw(scenario)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	if fail {
+		if err, ok := memoryErrors[scenario.Error]; ok {
+			return nil, err
+		}
+		return nil, UpstreamUnavailableError
+	}
+	if !ok {
+		return nil, CepNotFoundError
+	}
 
 	return &CepServiceOutput{
-		Cep:    cep,
-		Temp_C: temperature,
-		Temp_K: toK(temperature),
-		Temp_F: toF(temperature),
-		City:   "Rio de Janeiro",
+		Cep:        cep,
+		Temp_C:     entry.Temp_C,
+		Temp_K:     temperature.ToKelvin(entry.Temp_C),
+		Temp_F:     temperature.ToFahrenheit(entry.Temp_C),
+		City:       entry.City,
+		Uf:         entry.Uf,
+		Source:     "memory",
+		ObservedAt: time.Now().UTC(),
 	}, nil
 }
 
-func toF(celsius float64) float64 {
-	return celsius*1.8 + 32
-}
+// draw returns the delay of a lookup and whether it fails, under scenario.
+func (s *MemoryCepService) draw(scenario MemoryScenario) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func toK(celsius float64) float64 {
-	return celsius + 273
+	delay := time.Duration(scenario.Latency)
+	if scenario.Jitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(scenario.Jitter) + 1))
+	}
+
+	if scenario.ErrorRate > 0 {
+		return delay, s.rand.Float64() < scenario.ErrorRate
+	}
+	return delay, scenario.Error != ""
 }
@@ -1,10 +1,17 @@
 package service
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestGetTemperature(t *testing.T) {
-	service := NewMemoryCepService()
-	res, err := service.GetTemperature("00000000")
+	ctx := context.Background()
+	service := NewMemoryCepService(nil, 1)
+
+	res, err := service.GetTemperature(ctx, "00000000")
 	if err != CepNotFoundError {
 		t.Fatal("invalid error getting temperature for 00000-000")
 	}
@@ -12,7 +19,7 @@ func TestGetTemperature(t *testing.T) {
 		t.Fatal("result not nil for getting temperature for 00000-000")
 	}
 
-	res, err = service.GetTemperature("0")
+	res, err = service.GetTemperature(ctx, "0")
 	if err != InvalidCepError {
 		t.Fatal("invalid error getting temperature for 0")
 	}
@@ -20,7 +27,7 @@ func TestGetTemperature(t *testing.T) {
 		t.Fatal("result not nil for getting temperature for 0")
 	}
 
-	res, err = service.GetTemperature("20561250")
+	res, err = service.GetTemperature(ctx, "20561250")
 	if err != nil {
 		t.Fatal("invalid error for getting temperature at 20561250")
 	}
@@ -32,7 +39,7 @@ func TestGetTemperature(t *testing.T) {
 		t.Fatal("Invalid City for 20561250")
 	}
 
-	if res.Temp_C <= 20.0 || res.Temp_C >= 21.0 {
+	if res.Temp_C != 20.5 {
 		t.Fatal("Invalid Temp_C for 20561250", res.Temp_C)
 	}
 	if res.Temp_K <= 293 || res.Temp_K >= 294.0 {
@@ -42,4 +49,97 @@ func TestGetTemperature(t *testing.T) {
 		t.Fatal("Invalid Temp_F for 20561250", res.Temp_F)
 	}
 
+	res, err = service.GetTemperature(ctx, "80010000")
+	if err != nil || res.City != "Curitiba" || res.Uf != "PR" || res.Temp_C != 12 {
+		t.Errorf("Expected Curitiba at 12°C, got %+v, %v", res, err)
+	}
+
+	res, err = service.GetTemperature(ctx, "12345678")
+	if err != nil || res.City != "Rio de Janeiro" {
+		t.Errorf("Expected the fallback entry for an unknown CEP, got %+v, %v", res, err)
+	}
+}
+
+const scenarioFixture = `{
+  "ceps": {
+    "20561250": {"city": "Rio de Janeiro", "uf": "RJ", "temp_C": 20.5},
+    "01001000": {"city": "São Paulo", "uf": "SP", "temp_C": 18.2, "scenario": {"error": "timeout"}},
+    "80010000": {"city": "Curitiba", "uf": "PR", "temp_C": 12, "scenario": {"latency": "1s"}}
+  },
+  "scenario": {"latency": "1ms", "jitter": "2ms", "error_rate": 0.5, "error": "rate_limited"}
+}`
+
+func readFixture(t *testing.T, fixture string) *MemoryFixture {
+	t.Helper()
+	f, err := ReadMemoryFixture(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestMemoryCepServiceScenarios(t *testing.T) {
+	ctx := context.Background()
+	fixture := readFixture(t, scenarioFixture)
+
+	outcomes := func(seed int64) []error {
+		service := NewMemoryCepService(fixture, seed)
+		errs := make([]error, 20)
+		for i := range errs {
+			_, errs[i] = service.GetTemperature(ctx, "20561250")
+		}
+		return errs
+	}
+
+	first, replay := outcomes(42), outcomes(42)
+	failures := 0
+	for i := range first {
+		if first[i] != replay[i] {
+			t.Fatalf("Expected the same seed to replay the scenario, lookup %d got %v then %v", i, first[i], replay[i])
+		}
+		if first[i] != nil {
+			failures++
+			if first[i] != UpstreamRateLimitedError {
+				t.Errorf("Expected UpstreamRateLimitedError, got %v", first[i])
+			}
+		}
+	}
+	if failures == 0 || failures == len(first) {
+		t.Errorf("Expected about half the lookups to fail, got %d of %d", failures, len(first))
+	}
+
+	service := NewMemoryCepService(fixture, 42)
+	if _, err := service.GetTemperature(ctx, "01001000"); err != UpstreamTimeoutError {
+		t.Errorf("Expected the CEP scenario to fail with UpstreamTimeoutError, got %v", err)
+	}
+	if _, err := service.GetTemperature(ctx, "99999999"); err != CepNotFoundError && err != UpstreamRateLimitedError {
+		t.Errorf("Expected an unknown CEP not to be found without fallback entry, got %v", err)
+	}
+}
+
+func TestMemoryCepServiceLatency(t *testing.T) {
+	service := NewMemoryCepService(readFixture(t, scenarioFixture), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := service.GetTemperature(ctx, "80010000"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to cut the latency short, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the lookup to give up at the deadline, took %s", elapsed)
+	}
+}
+
+func TestReadMemoryFixtureInvalid(t *testing.T) {
+	for _, fixture := range []string{
+		`{"scenario": {"error_rate": 2}}`,
+		`{"scenario": {"latency": "soon"}}`,
+		`{"ceps": {"20561250": {"scenario": {"error": "exploded"}}}}`,
+	} {
+		if _, err := ReadMemoryFixture(strings.NewReader(fixture)); err == nil {
+			t.Errorf("%s: expected error", fixture)
+		}
+	}
 }
@@ -3,65 +3,295 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/circuit"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/coalesce"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/retry"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type BService struct{}
+const (
+	DefaultBServiceBaseURL   = "http://serviceb:8181"
+	DefaultBServiceTimeout   = 5 * time.Second
+	DefaultBServiceUserAgent = "service-a"
+)
+
+type BServiceOptions struct {
+	// BaseURL is the scheme and host of service-b, e.g. http://serviceb:8181.
+	BaseURL string
+	// Timeout bounds every request made to service-b.
+	Timeout time.Duration
+	// Transport is used to perform requests, http.DefaultTransport when nil.
+	Transport http.RoundTripper
+	// TLSConfig is applied to the transport when BaseURL uses https. It is
+	// ignored when Transport is set to anything but an *http.Transport,
+	// e.g. a wrapping transport, which must then carry its own TLS config.
+	TLSConfig *tls.Config
+	UserAgent string
+	// Breaker fails requests fast while service-b keeps failing, no
+	// circuit breaking when nil.
+	Breaker *circuit.Breaker
+	// Retry retries requests failing with a connection error or a 5xx
+	// answer, no retries when nil.
+	Retry *retry.Options
+}
+
+// ServiceBError is returned for error answers from service-b and keeps the
+// problem details it reported.
+type ServiceBError struct {
+	Problem *problem.Problem
+	Err     error
+}
+
+func (e *ServiceBError) Error() string {
+	return fmt.Sprintf("service-b answered with status %d: %v: %s", e.Problem.Status, e.Err, e.Problem.Title)
+}
+
+func (e *ServiceBError) Unwrap() error {
+	return e.Err
+}
 
-func NewBService() *BService {
-	return &BService{}
+type BService struct {
+	baseURL   string
+	timeout   time.Duration
+	userAgent string
+	client    *http.Client
+	// transport is the transport of client without retries.
+	transport http.RoundTripper
+	breaker   *circuit.Breaker
+	// temperatures coalesces concurrent lookups of the same CEP.
+	temperatures *coalesce.Group[*CepServiceOutput]
+}
+
+func NewBService(opts BServiceOptions) *BService {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBServiceBaseURL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultBServiceTimeout
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultBServiceUserAgent
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+		defaultTransport.TLSClientConfig = opts.TLSConfig
+		transport = defaultTransport
+	} else if t, ok := transport.(*http.Transport); ok && opts.TLSConfig != nil {
+		t = t.Clone()
+		t.TLSClientConfig = opts.TLSConfig
+		transport = t
+	}
+	client := &http.Client{Transport: transport}
+	if opts.Retry != nil {
+		client.Transport = retry.NewTransport(transport, *opts.Retry)
+	}
+
+	return &BService{
+		baseURL:      strings.TrimSuffix(opts.BaseURL, "/"),
+		timeout:      opts.Timeout,
+		userAgent:    opts.UserAgent,
+		client:       client,
+		transport:    transport,
+		breaker:      opts.Breaker,
+		temperatures: coalesce.NewGroup[*CepServiceOutput]("service-b"),
+	}
 }
 
 func (b *BService) Name() string {
 	return "B Cep Service"
 }
 
-func (b *BService) GetTemperature(ctx context.Context, cep string) (*CepServiceOutput, error) {
+func (b *BService) GetTemperature(ctx context.Context, cep string) (_ *CepServiceOutput, err error) {
 	tr := otel.Tracer("a-b-trace")
 	ctx, span := tr.Start(ctx, "BService.GetTemperature")
 	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	return b.temperatures.Do(ctx, cep, func(ctx context.Context) (_ *CepServiceOutput, err error) {
+		// The call is shared with the followers and may outlive the
+		// leader's span, so it records on a span of its own, linked to the
+		// leader's one.
+		ctx, span := tr.Start(ctx, "BService.GetTemperature lookup", trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(ctx)))
+		defer span.End()
+		defer func() { recordError(span, err) }()
+
+		// Every extended field is requested, the handler keeps the ones
+		// its client asked for.
+		query := url.Values{}
+		query.Set("cep", cep)
+		query.Set("fields", conditions.All)
+
+		var output *CepServiceOutput
+		if err := b.get(ctx, "/", query, &output); err != nil {
+			return nil, err
+		}
+
+		return output, nil
+	})
+}
+
+func (b *BService) GetForecast(ctx context.Context, input ForecastInput) (_ *ForecastOutput, err error) {
+	tr := otel.Tracer("a-b-trace")
+	ctx, span := tr.Start(ctx, "BService.GetForecast")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	query := url.Values{}
+	query.Set("cep", input.Cep)
+	query.Set("days", strconv.Itoa(input.Days))
+	query.Set("hourly", strconv.FormatBool(input.Hourly))
+
+	var output *ForecastOutput
+	if err := b.get(ctx, "/forecast", query, &output); err != nil {
+		return nil, err
+	}
 
-	var output *CepServiceOutput
+	return output, nil
+}
+
+// Ping checks service-b is ready, answering its readiness endpoint with
+// 200, which it does while at least one CEP and one weather provider can
+// be called. The circuit breaker and retries are bypassed, so the check
+// reflects service-b itself.
+func (b *BService) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, b.baseURL+"/readyz", nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", b.userAgent)
+
+	response, err := b.transport.RoundTrip(request)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", UpstreamUnavailableError, b.baseURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s is not ready, answered with status %d", UpstreamUnavailableError, b.baseURL, response.StatusCode)
+	}
+	return nil
+}
+
+// get requests path from service-b and decodes the JSON answer into out,
+// mapping error answers to the matching errors.
+func (b *BService) get(ctx context.Context, path string, query url.Values, out any) (err error) {
+	if b.breaker != nil {
+		done, allowErr := b.breaker.Allow(ctx)
+		if allowErr != nil {
+			return fmt.Errorf("%w: %s", CircuitOpenError, b.baseURL)
+		}
+		defer func() { done(circuitOutcome(err)) }()
+	}
+
+	caller := ctx
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
 
-	url := fmt.Sprintf("http://serviceb:8181/?cep=%s", cep)
+	requestURL := fmt.Sprintf("%s%s?%s", b.baseURL, path, query.Encode())
 
 	request, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodGet,
-		url,
+		requestURL,
 		bytes.NewReader(nil),
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer request.Body.Close()
 
+	request.Header.Set("User-Agent", b.userAgent)
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
-	response, err := http.DefaultClient.Do(request)
+	response, err := b.client.Do(request)
 	if err != nil {
-		return nil, err
+		// The cause is kept wrapped so that calls cancelled by the caller
+		// are told apart by circuitOutcome.
+		switch {
+		case errors.Is(caller.Err(), context.DeadlineExceeded):
+			return fmt.Errorf("%w: no response from %s before the caller's deadline: %w", UpstreamTimeoutError, b.baseURL, err)
+		case errors.Is(err, context.DeadlineExceeded):
+			return fmt.Errorf("%w: no response from %s after %s: %w", UpstreamTimeoutError, b.baseURL, b.timeout, err)
+		}
+		return fmt.Errorf("%w: %s: %w", UpstreamUnavailableError, b.baseURL, err)
 	}
 
 	defer response.Body.Close()
 
 	if response.StatusCode == http.StatusNotFound {
-		return nil, CepNotFoundError
+		return &ServiceBError{Problem: problem.Parse(response), Err: CepNotFoundError}
+	}
+
+	if response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnprocessableEntity {
+		return &ServiceBError{Problem: problem.Parse(response), Err: InvalidCepError}
 	}
 
-	if response.StatusCode == http.StatusBadRequest {
-		return nil, InvalidCepError
+	if response.StatusCode != http.StatusOK {
+		return &ServiceBError{Problem: problem.Parse(response), Err: statusError(response.StatusCode)}
 	}
 
-	err = json.NewDecoder(response.Body).Decode(&output)
+	err = json.NewDecoder(response.Body).Decode(out)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%w: %v", UpstreamBadPayloadError, err)
 	}
 
-	return output, nil
+	return nil
+}
+
+// circuitOutcome returns the outcome of a call to service-b failing with
+// err. Client errors are successes, calls cancelled by the caller are
+// ignored.
+func circuitOutcome(err error) circuit.Outcome {
+	switch {
+	case err == nil, errors.Is(err, CepNotFoundError), errors.Is(err, InvalidCepError):
+		return circuit.Success
+	case errors.Is(err, context.Canceled):
+		return circuit.Ignored
+	default:
+		return circuit.Failure
+	}
+}
+
+// recordError records err on span. Not found and invalid CEPs are client
+// errors and keep the span status unset.
+func recordError(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, CepNotFoundError) && !errors.Is(err, InvalidCepError) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// statusError maps the status answered by service-b, which already
+// classifies its own upstream failures, to the matching error.
+func statusError(statusCode int) error {
+	switch statusCode {
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return UpstreamTimeoutError
+	case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return UpstreamRateLimitedError
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return UpstreamAuthError
+	default:
+		return UpstreamUnavailableError
+	}
 }
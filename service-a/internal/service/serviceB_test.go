@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/circuit"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/retry"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBServiceGetTemperature(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cep") != "20561250" {
+			t.Errorf("Expected cep 20561250, got %s", r.URL.Query().Get("cep"))
+		}
+		if r.UserAgent() != "test-agent" {
+			t.Errorf("Expected user agent test-agent, got %s", r.UserAgent())
+		}
+		if r.URL.Query().Get("fields") != "all" {
+			t.Errorf("Expected every extended field requested, got %s", r.URL.Query().Get("fields"))
+		}
+		w.Write([]byte(`{"city":"Rio de Janeiro","temp_C":20,"temp_F":68,"temp_K":293.15,"humidity":82,"wind_dir":"SE","uf":"RJ","source":"weatherapi","observed_at":"2024-06-01T12:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL, UserAgent: "test-agent"})
+	output, err := service.GetTemperature(context.Background(), "20561250")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if output.City != "Rio de Janeiro" {
+		t.Errorf("Expected Rio de Janeiro, got %s", output.City)
+	}
+	if output.Temp_C != 20 {
+		t.Errorf("Expected 20, got %v", output.Temp_C)
+	}
+	if output.Uf != "RJ" || output.Source != "weatherapi" || !output.ObservedAt.Equal(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected reading metadata, got %+v", output)
+	}
+	if output.Humidity == nil || *output.Humidity != 82 || output.WindDir != "SE" {
+		t.Errorf("Expected extended conditions, got %+v", output.Extended)
+	}
+}
+
+func TestBServiceGetTemperatureTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL, Timeout: 10 * time.Millisecond})
+	_, err := service.GetTemperature(context.Background(), "20561250")
+	if !errors.Is(err, UpstreamTimeoutError) {
+		t.Fatal("Expected UpstreamTimeoutError, got", err)
+	}
+}
+
+func TestBServiceGetTemperatureUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL})
+	_, err := service.GetTemperature(context.Background(), "20561250")
+	if !errors.Is(err, UpstreamUnavailableError) {
+		t.Fatal("Expected UpstreamUnavailableError, got", err)
+	}
+}
+
+func TestBServiceGetTemperatureUpstreamErrors(t *testing.T) {
+	expected := map[int]error{
+		http.StatusBadGateway:          UpstreamUnavailableError,
+		http.StatusInternalServerError: UpstreamUnavailableError,
+		http.StatusServiceUnavailable:  UpstreamRateLimitedError,
+		http.StatusGatewayTimeout:      UpstreamTimeoutError,
+	}
+
+	for statusCode, kind := range expected {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+
+		service := NewBService(BServiceOptions{BaseURL: server.URL})
+		_, err := service.GetTemperature(context.Background(), "20561250")
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected %v for status %d, got %v", kind, statusCode, err)
+		}
+		server.Close()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`not json`))
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL})
+	_, err := service.GetTemperature(context.Background(), "20561250")
+	if !errors.Is(err, UpstreamBadPayloadError) {
+		t.Errorf("Expected UpstreamBadPayloadError, got %v", err)
+	}
+}
+
+func TestBServiceGetTemperatureRetry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"city":"Rio de Janeiro","temp_C":20,"temp_F":68,"temp_K":293.15}`))
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL, Retry: &retry.Options{BaseDelay: time.Millisecond}})
+	output, err := service.GetTemperature(context.Background(), "20561250")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if output.City != "Rio de Janeiro" || calls != 2 {
+		t.Errorf("Expected Rio de Janeiro after a retry, got %+v after %d calls", output, calls)
+	}
+}
+
+func TestBServiceGetTemperatureProblem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(r.Context(), w, r, problem.New(http.StatusBadGateway, problem.CodeUpstreamUnavailable, "upstream provider unavailable").
+			WithUpstream(&problem.Upstream{Name: "viacep", Status: http.StatusInternalServerError}))
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL})
+	_, err := service.GetTemperature(context.Background(), "20561250")
+	if !errors.Is(err, UpstreamUnavailableError) {
+		t.Fatalf("Expected UpstreamUnavailableError, got %v", err)
+	}
+
+	var serviceBErr *ServiceBError
+	if !errors.As(err, &serviceBErr) {
+		t.Fatalf("Expected ServiceBError, got %T", err)
+	}
+	if serviceBErr.Problem.Code != problem.CodeUpstreamUnavailable {
+		t.Errorf("Expected %s, got %s", problem.CodeUpstreamUnavailable, serviceBErr.Problem.Code)
+	}
+	if serviceBErr.Problem.Upstream == nil || serviceBErr.Problem.Upstream.Name != "viacep" {
+		t.Errorf("Expected viacep upstream, got %+v", serviceBErr.Problem.Upstream)
+	}
+}
+
+func TestBServiceGetForecast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/forecast" {
+			t.Errorf("Expected /forecast, got %s", r.URL.Path)
+		}
+		if query := r.URL.RawQuery; query != "cep=80010000&days=2&hourly=true" {
+			t.Errorf("Unexpected query %s", query)
+		}
+		w.Write([]byte(`{"city":"Curitiba","days":[{"date":"2024-06-01","min_temp_C":10,"max_temp_C":20,"max_temp_K":293.15,"condition":"Sunny","condition_code":1000,"hourly":[{"time":"2024-06-01T00:00:00Z","temp_C":12}]}]}`))
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL})
+	output, err := service.GetForecast(context.Background(), ForecastInput{Cep: "80010000", Days: 2, Hourly: true})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if output.City != "Curitiba" || len(output.Days) != 1 {
+		t.Fatalf("Unexpected forecast %+v", output)
+	}
+	if day := output.Days[0]; day.MaxTemp_K != 293.15 || day.Condition != "Sunny" || len(day.Hourly) != 1 {
+		t.Errorf("Unexpected day %+v", day)
+	}
+}
+
+func TestBServiceCircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("cep") == "00000000" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{
+		BaseURL: server.URL,
+		Breaker: circuit.NewBreaker("test-service-b", circuit.Options{FailureThreshold: 2, OpenTimeout: time.Hour}),
+	})
+
+	service.GetTemperature(context.Background(), "00000000")
+	service.GetTemperature(context.Background(), "00000000")
+	service.GetTemperature(context.Background(), "20561250")
+	service.GetTemperature(context.Background(), "20561250")
+
+	_, err := service.GetTemperature(context.Background(), "20561250")
+	if !errors.Is(err, CircuitOpenError) {
+		t.Fatalf("Expected CircuitOpenError, got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected the open circuit to fail fast, got %d calls", calls)
+	}
+}
+
+func TestBServiceCircuitBreakerIgnoresCancelledCalls(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forecast" {
+			arrived <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	breaker := circuit.NewBreaker("test-cancelled", circuit.Options{FailureThreshold: 2, OpenTimeout: time.Hour})
+	service := NewBService(BServiceOptions{BaseURL: server.URL, Breaker: breaker})
+
+	service.GetTemperature(context.Background(), "20561250")
+
+	// A client going away from /forecast, which is not coalesced.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	if _, err := service.GetForecast(ctx, ForecastInput{Cep: "20561250", Days: 3}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if breaker.State() != circuit.Closed {
+		t.Fatalf("Expected a cancelled call not to count as a failure, got %s", breaker.State())
+	}
+
+	// Had the cancelled call reset the failures, this one would not open
+	// the circuit.
+	service.GetTemperature(context.Background(), "01001000")
+	if breaker.State() != circuit.Open {
+		t.Errorf("Expected the failures before the cancelled call to be kept, got %s", breaker.State())
+	}
+}
+
+func TestBServiceGetTemperatureCallerDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL, Timeout: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := service.GetForecast(ctx, ForecastInput{Cep: "20561250", Days: 3})
+	if !errors.Is(err, UpstreamTimeoutError) {
+		t.Fatalf("Expected UpstreamTimeoutError, got %v", err)
+	}
+	if strings.Contains(err.Error(), "1h0m0s") {
+		t.Errorf("Expected the caller's deadline reported rather than the timeout, got %v", err)
+	}
+}
+
+func TestBServicePing(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			t.Errorf("Expected /readyz, got %s", r.URL.Path)
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"unavailable"}`))
+		}
+	}))
+	defer server.Close()
+
+	service := NewBService(BServiceOptions{BaseURL: server.URL})
+	if err := service.Ping(context.Background()); err != nil {
+		t.Errorf("Expected service-b to be reachable, got %v", err)
+	}
+
+	healthy = false
+	if err := service.Ping(context.Background()); !errors.Is(err, UpstreamUnavailableError) {
+		t.Errorf("Expected UpstreamUnavailableError, got %v", err)
+	}
+
+	server.Close()
+	if err := service.Ping(context.Background()); !errors.Is(err, UpstreamUnavailableError) {
+		t.Errorf("Expected UpstreamUnavailableError, got %v", err)
+	}
+}
+
+func TestBServiceGetTemperatureLookupSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"city":"Rio de Janeiro","temp_C":20}`))
+	}))
+	defer server.Close()
+	service := NewBService(BServiceOptions{BaseURL: server.URL})
+
+	// The leader goes away while the lookup it started is in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.GetTemperature(ctx, "20561250"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	close(release)
+
+	var leader, lookup sdktrace.ReadOnlySpan
+	deadline := time.Now().Add(5 * time.Second)
+	for lookup == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the lookup span to end")
+		}
+		time.Sleep(time.Millisecond)
+		for _, span := range recorder.Ended() {
+			switch span.Name() {
+			case "BService.GetTemperature":
+				leader = span
+			case "BService.GetTemperature lookup":
+				lookup = span
+			}
+		}
+	}
+
+	if leader == nil || !lookup.EndTime().After(leader.EndTime()) {
+		t.Fatal("Expected the lookup span to end after the leader's one")
+	}
+	if lookup.Parent().IsValid() {
+		t.Errorf("Expected the lookup span not to be a child of the leader's one, got parent %s", lookup.Parent().SpanID())
+	}
+	if links := lookup.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != leader.SpanContext().SpanID() {
+		t.Errorf("Expected the lookup span linked to the leader's one, got %+v", links)
+	}
+}
@@ -0,0 +1,100 @@
+// Command fakeupstream emulates the ViaCep and WeatherAPI endpoints called
+// by Service B from fixture data, so the stack runs offline and without a
+// WeatherAPI key. Latency and errors can be injected to exercise retries,
+// hedging, circuit breakers and the weather fallback.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/health"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	viper.AutomaticEnv()
+	viper.SetDefault("HTTP_PORT", "8282")
+	viper.SetDefault("SHUTDOWN_GRACE_PERIOD", 10*time.Second)
+	viper.SetDefault("FAKE_UPSTREAM_LATENCY", time.Duration(0))
+	viper.SetDefault("FAKE_UPSTREAM_JITTER", time.Duration(0))
+	viper.SetDefault("FAKE_UPSTREAM_ERROR_RATE", 0.0)
+	viper.SetDefault("FAKE_UPSTREAM_ERROR_STATUS", http.StatusServiceUnavailable)
+	viper.SetDefault("FAKE_UPSTREAM_SEED", 0)
+}
+
+func main() {
+	webServerPort := viper.GetString("HTTP_PORT")
+
+	fixture, err := loadFixture(viper.GetString("FAKE_UPSTREAM_FIXTURE"))
+	if err != nil {
+		log.Println("Error loading fixture: ", err)
+		return
+	}
+
+	// A zero seed picks a different one on every start.
+	seed := viper.GetInt64("FAKE_UPSTREAM_SEED")
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	log.Println("Fake upstream seed: ", seed)
+
+	upstream := newUpstream(fixture, Faults{
+		Latency:     viper.GetDuration("FAKE_UPSTREAM_LATENCY"),
+		Jitter:      viper.GetDuration("FAKE_UPSTREAM_JITTER"),
+		ErrorRate:   viper.GetFloat64("FAKE_UPSTREAM_ERROR_RATE"),
+		ErrorStatus: viper.GetInt("FAKE_UPSTREAM_ERROR_STATUS"),
+	}, viper.GetString("FAKE_UPSTREAM_API_KEY"), seed)
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Get("/healthz", health.Live)
+	r.Mount("/", upstream.routes())
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", webServerPort),
+		Handler: r,
+	}
+
+	ctx, cancel := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	defer cancel()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Println("Fake upstream running at :", webServerPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down gracefully, signal received")
+	case err := <-serverErr:
+		log.Println("Error running server, shutting down: ", err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(
+		context.Background(),
+		viper.GetDuration("SHUTDOWN_GRACE_PERIOD"),
+	)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error draining connections: ", err)
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// defaultFixture is the embedded fixture, in the format read by readFixture.
+//
+//go:embed fixture.json
+var defaultFixture string
+
+// maxCityDistance is how far, in degrees, coordinates may be from a city to
+// be answered with its weather.
+const maxCityDistance = 1.0
+
+// Fixture holds the data answered by the fake upstreams: ViaCep addresses
+// by CEP, WeatherAPI cities and the status codes some of them always fail
+// with.
+type Fixture struct {
+	Ceps     map[string]json.RawMessage `json:"ceps"`
+	Cities   []City                     `json:"cities"`
+	Failures struct {
+		Ceps   map[string]int `json:"ceps"`
+		Cities map[string]int `json:"cities"`
+	} `json:"failures"`
+}
+
+type City struct {
+	Name       string  `json:"name"`
+	Region     string  `json:"region"`
+	Country    string  `json:"country"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	TempC      float64 `json:"temp_c"`
+	Humidity   float64 `json:"humidity"`
+	WindKph    float64 `json:"wind_kph"`
+	WindDegree int     `json:"wind_degree"`
+	WindDir    string  `json:"wind_dir"`
+	PressureMb float64 `json:"pressure_mb"`
+	PrecipMm   float64 `json:"precip_mm"`
+	UV         float64 `json:"uv"`
+	FeelsLikeC float64 `json:"feelslike_c"`
+	Condition  struct {
+		Text string `json:"text"`
+		Code int    `json:"code"`
+	} `json:"condition"`
+}
+
+func readFixture(r io.Reader) (*Fixture, error) {
+	fixture := &Fixture{}
+	if err := json.NewDecoder(r).Decode(fixture); err != nil {
+		return nil, fmt.Errorf("reading fixture: %w", err)
+	}
+	return fixture, nil
+}
+
+// loadFixture reads the fixture stored at path, or the embedded one when
+// path is empty.
+func loadFixture(path string) (*Fixture, error) {
+	if path == "" {
+		return readFixture(strings.NewReader(defaultFixture))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readFixture(f)
+}
+
+// Faults are injected into every upstream request.
+type Faults struct {
+	// Latency delays every answer, plus a random delay up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is the share of requests failing with ErrorStatus, from 0
+	// to 1.
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+// faultsDocument is the JSON form of Faults, with durations as strings.
+type faultsDocument struct {
+	Latency     string  `json:"latency"`
+	Jitter      string  `json:"jitter"`
+	ErrorRate   float64 `json:"error_rate"`
+	ErrorStatus int     `json:"error_status"`
+}
+
+// upstream serves the fake ViaCep and WeatherAPI endpoints.
+type upstream struct {
+	fixture *Fixture
+	// apiKey is the WeatherAPI key accepted, any when empty.
+	apiKey string
+
+	mu     sync.Mutex
+	faults Faults
+	rand   *rand.Rand
+}
+
+func newUpstream(fixture *Fixture, faults Faults, apiKey string, seed int64) *upstream {
+	return &upstream{
+		fixture: fixture,
+		apiKey:  apiKey,
+		faults:  faults,
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (u *upstream) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/_faults", u.getFaults)
+	r.Put("/_faults", u.putFaults)
+	r.Group(func(r chi.Router) {
+		r.Use(u.inject)
+		r.Get("/ws/{cep}/json", u.viaCep)
+		r.Get("/v1/current.json", u.current)
+		r.Get("/v1/search.json", u.search)
+	})
+	return r
+}
+
+// inject delays and fails requests according to the faults.
+func (u *upstream) inject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay, fail, status := u.draw()
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		if fail {
+			failWith(w, r, status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (u *upstream) draw() (time.Duration, bool, int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delay := u.faults.Latency
+	if u.faults.Jitter > 0 {
+		delay += time.Duration(u.rand.Int63n(int64(u.faults.Jitter) + 1))
+	}
+	fail := u.faults.ErrorRate > 0 && u.rand.Float64() < u.faults.ErrorRate
+	return delay, fail, u.faults.ErrorStatus
+}
+
+func (u *upstream) getFaults(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	faults := u.faults
+	u.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, faultsDocument{
+		Latency:     faults.Latency.String(),
+		Jitter:      faults.Jitter.String(),
+		ErrorRate:   faults.ErrorRate,
+		ErrorStatus: faults.ErrorStatus,
+	})
+}
+
+// putFaults replaces the faults injected, so a running stack can be made
+// slow or failing without restarting it.
+func (u *upstream) putFaults(w http.ResponseWriter, r *http.Request) {
+	document := faultsDocument{Latency: "0s", Jitter: "0s", ErrorStatus: http.StatusServiceUnavailable}
+	if err := json.NewDecoder(r.Body).Decode(&document); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	latency, err := time.ParseDuration(document.Latency)
+	if err != nil {
+		http.Error(w, "invalid latency: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	jitter, err := time.ParseDuration(document.Jitter)
+	if err != nil {
+		http.Error(w, "invalid jitter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if document.ErrorRate < 0 || document.ErrorRate > 1 {
+		http.Error(w, "error_rate out of [0, 1]", http.StatusBadRequest)
+		return
+	}
+	if document.ErrorStatus < 400 || document.ErrorStatus > 599 {
+		http.Error(w, "error_status is not an error status", http.StatusBadRequest)
+		return
+	}
+
+	u.mu.Lock()
+	u.faults = Faults{Latency: latency, Jitter: jitter, ErrorRate: document.ErrorRate, ErrorStatus: document.ErrorStatus}
+	u.mu.Unlock()
+
+	u.getFaults(w, r)
+}
+
+// viaCep emulates GET /ws/{cep}/json, answering unknown CEPs as ViaCep
+// does: 200 with {"erro": true}.
+func (u *upstream) viaCep(w http.ResponseWriter, r *http.Request) {
+	cep := strings.ReplaceAll(chi.URLParam(r, "cep"), "-", "")
+	if _, err := strconv.Atoi(cep); err != nil || len(cep) != 8 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if status, ok := u.fixture.Failures.Ceps[cep]; ok {
+		failWith(w, r, status)
+		return
+	}
+
+	address, ok := u.fixture.Ceps[cep]
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]bool{"erro": true})
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+// current emulates GET /v1/current.json for a city name or coordinates.
+func (u *upstream) current(w http.ResponseWriter, r *http.Request) {
+	if !u.authorized(w, r) {
+		return
+	}
+
+	city, ok := u.findCity(r.URL.Query().Get("q"))
+	if !ok {
+		weatherApiError(w, http.StatusBadRequest, 1006, "No matching location found.")
+		return
+	}
+	if status, ok := u.fixture.Failures.Cities[city.Name]; ok {
+		failWith(w, r, status)
+		return
+	}
+
+	updated := time.Now().Truncate(15 * time.Minute)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"location": map[string]any{
+			"name":            city.Name,
+			"region":          city.Region,
+			"country":         city.Country,
+			"lat":             city.Lat,
+			"lon":             city.Lon,
+			"localtime_epoch": time.Now().Unix(),
+		},
+		"current": map[string]any{
+			"last_updated_epoch": updated.Unix(),
+			"temp_c":             city.TempC,
+			"temp_f":             city.TempC*9/5 + 32,
+			"condition":          city.Condition,
+			"wind_kph":           city.WindKph,
+			"wind_degree":        city.WindDegree,
+			"wind_dir":           city.WindDir,
+			"pressure_mb":        city.PressureMb,
+			"precip_mm":          city.PrecipMm,
+			"humidity":           city.Humidity,
+			"feelslike_c":        city.FeelsLikeC,
+			"feelslike_f":        city.FeelsLikeC*9/5 + 32,
+			"uv":                 city.UV,
+		},
+	})
+}
+
+// search emulates GET /v1/search.json, matching city names containing q.
+func (u *upstream) search(w http.ResponseWriter, r *http.Request) {
+	if !u.authorized(w, r) {
+		return
+	}
+
+	q := foldName(r.URL.Query().Get("q"))
+	results := []map[string]any{}
+	for i, city := range u.fixture.Cities {
+		if q == "" || !strings.Contains(foldName(city.Name), q) {
+			continue
+		}
+		results = append(results, map[string]any{
+			"id":      i + 1,
+			"name":    city.Name,
+			"region":  city.Region,
+			"country": city.Country,
+			"lat":     city.Lat,
+			"lon":     city.Lon,
+		})
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// authorized checks the WeatherAPI key, answering the WeatherAPI errors
+// when it is missing or refused.
+func (u *upstream) authorized(w http.ResponseWriter, r *http.Request) bool {
+	key := r.URL.Query().Get("key")
+	switch {
+	case key == "":
+		weatherApiError(w, http.StatusUnauthorized, 1002, "API key is invalid or not provided.")
+		return false
+	case u.apiKey != "" && key != u.apiKey:
+		weatherApiError(w, http.StatusUnauthorized, 2006, "API key is invalid.")
+		return false
+	}
+	return true
+}
+
+// findCity returns the city named q, or the nearest to the lat,lon
+// coordinates in q.
+func (u *upstream) findCity(q string) (City, bool) {
+	if lat, lon, ok := parseCoordinates(q); ok {
+		best, bestDistance := -1, maxCityDistance*maxCityDistance
+		for i, city := range u.fixture.Cities {
+			distance := (city.Lat-lat)*(city.Lat-lat) + (city.Lon-lon)*(city.Lon-lon)
+			if distance <= bestDistance {
+				best, bestDistance = i, distance
+			}
+		}
+		if best < 0 {
+			return City{}, false
+		}
+		return u.fixture.Cities[best], true
+	}
+
+	for _, city := range u.fixture.Cities {
+		if foldName(city.Name) == foldName(q) {
+			return city, true
+		}
+	}
+	return City{}, false
+}
+
+func parseCoordinates(q string) (float64, float64, bool) {
+	latitude, longitude, ok := strings.Cut(q, ",")
+	if !ok {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latitude), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(longitude), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// failWith answers an injected failure, in the error format of the upstream
+// called.
+func failWith(w http.ResponseWriter, r *http.Request, status int) {
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+	}
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		code := 9999
+		if status == http.StatusTooManyRequests {
+			code = 2007
+		}
+		weatherApiError(w, status, code, http.StatusText(status))
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+func weatherApiError(w http.ResponseWriter, status, code int, message string) {
+	body := map[string]any{"error": map[string]any{"code": code, "message": message}}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+var accents = runes.Remove(runes.In(unicode.Mn))
+
+// foldName lowercases name and strips its accents, so "São Paulo" matches
+// "Sao Paulo".
+func foldName(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, accents, norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+	return strings.ToLower(strings.TrimSpace(folded))
+}
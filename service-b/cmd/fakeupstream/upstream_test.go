@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
+)
+
+func newFakeUpstream(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+	fixture, err := loadFixture("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(newUpstream(fixture, Faults{}, apiKey, 1).routes())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestViaCep(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeUpstream(t, "")
+	viaCep := service.NewViaCepService(server.URL, nil)
+
+	address, err := viaCep.GetAddressByCep(ctx, "80010-000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Localidade != "Curitiba" || address.Ibge != "4106902" {
+		t.Errorf("Expected Curitiba, got %+v", address)
+	}
+
+	if _, err := viaCep.GetAddressByCep(ctx, "12345678"); err != service.CepNotFoundError {
+		t.Errorf("Expected CepNotFoundError, got %v", err)
+	}
+	if _, err := viaCep.GetAddressByCep(ctx, "99999999"); !errors.Is(err, service.UpstreamUnavailableError) {
+		t.Errorf("Expected the fixture failure, got %v", err)
+	}
+}
+
+func TestWeatherApi(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeUpstream(t, "secret")
+	weatherApi := service.NewWeatherApiService("secret", server.URL, nil)
+
+	curitiba := service.Location{City: "Curitiba", Uf: "PR", Country: service.CountryBrazil, Coordinates: &service.Coordinates{Latitude: -25.4195, Longitude: -49.2646}}
+	weather, err := weatherApi.GetWeatherByLocation(ctx, curitiba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Temp_c != 12 || weather.Temp_f != 53.6 {
+		t.Errorf("Expected 12°C, got %+v", weather)
+	}
+
+	// Without coordinates the city is searched first.
+	weather, err = weatherApi.GetWeatherByLocation(ctx, service.Location{City: "São Paulo", Uf: "SP", Country: service.CountryBrazil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Name != "Sao Paulo" || weather.Temp_c != 18.2 {
+		t.Errorf("Expected Sao Paulo at 18.2°C, got %+v", weather)
+	}
+
+	if _, err := weatherApi.GetWeatherByLocation(ctx, service.Location{City: "Lisboa", Country: service.CountryBrazil, Coordinates: &service.Coordinates{Latitude: 38.7, Longitude: -9.1}}); !errors.Is(err, service.LocationNotFoundError) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+
+	if err := service.NewWeatherApiService("wrong", server.URL, nil).CheckKey(ctx); !errors.Is(err, service.UpstreamAuthError) {
+		t.Errorf("Expected UpstreamAuthError, got %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeUpstream(t, "")
+	viaCep := service.NewViaCepService(server.URL, nil)
+
+	request, _ := http.NewRequest(http.MethodPut, server.URL+"/_faults", strings.NewReader(`{"error_rate": 1, "error_status": 504}`))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", response.StatusCode)
+	}
+
+	if _, err := viaCep.GetAddressByCep(ctx, "80010000"); !errors.Is(err, service.UpstreamTimeoutError) {
+		t.Errorf("Expected the injected 504, got %v", err)
+	}
+
+	request, _ = http.NewRequest(http.MethodPut, server.URL+"/_faults", strings.NewReader(`{"error_rate": 2}`))
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid error rate, got %d", response.StatusCode)
+	}
+}
@@ -2,73 +2,444 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/circuit"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/health"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/render"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/retry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/geocoding"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/handler"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/usecase"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/spf13/viper"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/zipkin"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
 func init() {
 	viper.AutomaticEnv()
+	viper.SetDefault("SHUTDOWN_GRACE_PERIOD", 10*time.Second)
+	viper.SetDefault("CEP_PROVIDERS", "viacep,brasilapi,opencep,awesomeapi")
+	viper.SetDefault("WEATHER_PROVIDERS", "weatherapi,openmeteo")
+	viper.SetDefault("GEOCODING_ENABLED", true)
+	viper.SetDefault("CACHE_ENABLED", true)
+	viper.SetDefault("CACHE_CEP_TTL", 24*time.Hour)
+	viper.SetDefault("CACHE_CEP_NEGATIVE_TTL", time.Hour)
+	viper.SetDefault("CACHE_CEP_SIZE", 10000)
+	viper.SetDefault("CACHE_WEATHER_TTL", 5*time.Minute)
+	viper.SetDefault("CACHE_WEATHER_SIZE", 1000)
+	viper.SetDefault("CACHE_FORECAST_TTL", 30*time.Minute)
+	viper.SetDefault("CACHE_FORECAST_SIZE", 1000)
+	viper.SetDefault("WEATHER_FALLBACK_ENABLED", true)
+	viper.SetDefault("WEATHER_FALLBACK_MAX_STALE", 6*time.Hour)
+	viper.SetDefault("WEATHER_FALLBACK_REFRESH_TIMEOUT", 10*time.Second)
+	viper.SetDefault("WEATHER_FALLBACK_SIZE", 1000)
+	viper.SetDefault("CIRCUIT_BREAKER_ENABLED", true)
+	viper.SetDefault("CIRCUIT_BREAKER_FAILURE_THRESHOLD", circuit.DefaultFailureThreshold)
+	viper.SetDefault("CIRCUIT_BREAKER_OPEN_TIMEOUT", circuit.DefaultOpenTimeout)
+	viper.SetDefault("CIRCUIT_BREAKER_HALF_OPEN_PROBES", circuit.DefaultHalfOpenProbes)
+	viper.SetDefault("CEP_HEDGE_ENABLED", false)
+	viper.SetDefault("CEP_HEDGE_PERCENTILE", 0.95)
+	viper.SetDefault("CEP_HEDGE_WINDOW", 200)
+	viper.SetDefault("CEP_HEDGE_MIN_DELAY", 20*time.Millisecond)
+	viper.SetDefault("CEP_HEDGE_MAX_DELAY", time.Second)
+	viper.SetDefault("HEALTH_DETAILED", false)
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", health.DefaultTimeout)
+	viper.SetDefault("HEALTH_API_KEY_CHECK_TTL", 5*time.Minute)
+	viper.SetDefault("HEALTH_PROBE_TIMEOUT", time.Second)
+	viper.SetDefault("HEALTH_PROBE_TTL", 30*time.Second)
+	viper.SetDefault("UPSTREAM_TIMEOUT", service.DefaultUpstreamTimeout)
+	viper.SetDefault("RETRY_ENABLED", true)
+	viper.SetDefault("RETRY_MAX_ATTEMPTS", retry.DefaultMaxAttempts)
+	viper.SetDefault("RETRY_BASE_DELAY", retry.DefaultBaseDelay)
+	viper.SetDefault("RETRY_MAX_DELAY", retry.DefaultMaxDelay)
+	viper.SetDefault("RETRY_BUDGET_RATIO", 0.2)
+	viper.SetDefault("RETRY_BUDGET_BURST", 10)
 }
 
-func initProvider() (func(context.Context) error, error) {
-	traceExporter, err := zipkin.New("http://zipkin:9411/api/v2/spans")
+func initProvider(ctx context.Context) (func(context.Context) error, error) {
+	return telemetry.InitTracerProvider(ctx, telemetry.Config{
+		ServiceName: "service-b",
+		Exporter:    viper.GetString("TRACES_EXPORTER"),
+		Endpoint:    viper.GetString("TRACES_EXPORTER_ENDPOINT"),
+		Headers:     telemetry.ParseHeaders(viper.GetString("TRACES_EXPORTER_HEADERS")),
+		Insecure:    viper.GetBool("TRACES_EXPORTER_INSECURE"),
+	})
+}
+
+func main() {
+	webServerPort := viper.GetString("HTTP_PORT")
+
+	cepService, err := cepServiceGateway(viper.GetString("CEP_PROVIDERS"))
 	if err != nil {
-		return nil, err
+		log.Println("Error configuring cep providers: ", err)
+		return
 	}
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
-	batcher := trace.NewBatchSpanProcessor(traceExporter)
+	if viper.GetBool("GEOCODING_ENABLED") {
+		geocoder, err := newGeocoder(viper.GetString("GEOCODING_DATASET"))
+		if err != nil {
+			log.Println("Error loading geocoding dataset: ", err)
+			return
+		}
+		log.Println("Geocoding municipalities: ", geocoder.Len())
+		cepService = service.NewGeocodingCepService(cepService, geocoder)
+	}
 
-	tp := trace.NewTracerProvider(
-		trace.WithSampler(trace.AlwaysSample()),
-		trace.WithSpanProcessor(batcher),
-		trace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String("service-b"),
-			),
-		),
-	)
-	otel.SetTracerProvider(tp)
+	weatherService, err := weatherServiceGateway(viper.GetString("WEATHER_PROVIDERS"))
+	if err != nil {
+		log.Println("Error configuring weather providers: ", err)
+		return
+	}
 
-	return tp.Shutdown, nil
-}
+	var forecastService service.ForecastService = service.NewWeatherApiService(viper.GetString("WEATHER_API_KEY"), viper.GetString("WEATHER_API_BASE_URL"), upstreamClient())
+	if breaker := circuitBreaker("weatherapi"); breaker != nil {
+		forecastService = service.NewCircuitBreakerForecastService(forecastService, breaker)
+	}
+
+	if viper.GetBool("WEATHER_FALLBACK_ENABLED") {
+		weatherService = service.NewFallbackWeatherService(weatherService, service.FallbackOptions{
+			MaxStale:       viper.GetDuration("WEATHER_FALLBACK_MAX_STALE"),
+			RefreshTimeout: viper.GetDuration("WEATHER_FALLBACK_REFRESH_TIMEOUT"),
+			Size:           viper.GetInt("WEATHER_FALLBACK_SIZE"),
+		})
+	}
+
+	if viper.GetBool("CACHE_ENABLED") {
+		cepService = service.NewCachedCepService(cepService, service.CacheOptions{
+			TTL:         viper.GetDuration("CACHE_CEP_TTL"),
+			NegativeTTL: viper.GetDuration("CACHE_CEP_NEGATIVE_TTL"),
+			Size:        viper.GetInt("CACHE_CEP_SIZE"),
+		})
+		weatherService = service.NewCachedWeatherService(weatherService, service.CacheOptions{
+			TTL:  viper.GetDuration("CACHE_WEATHER_TTL"),
+			Size: viper.GetInt("CACHE_WEATHER_SIZE"),
+		})
+		forecastService = service.NewCachedForecastService(forecastService, service.CacheOptions{
+			TTL:  viper.GetDuration("CACHE_FORECAST_TTL"),
+			Size: viper.GetInt("CACHE_FORECAST_SIZE"),
+		})
+	}
 
-func main() {
 	var (
-		webServerPort                = viper.GetString("HTTP_PORT")
-		weatherApiKey                = viper.GetString("WEATHER_API_KEY")
-		weatherService               = service.NewWeatherApiService(weatherApiKey)
-		cepService                   = service.NewViaCepService()
 		getTemperatureFromCepUseCase = usecase.NewGetTemperatureFromCepUseCase(cepService, weatherService)
 		getTemperatureHandler        = handler.NewGetTemperatureHandler(getTemperatureFromCepUseCase)
+		getForecastFromCepUseCase    = usecase.NewGetForecastFromCepUseCase(cepService, forecastService)
+		getForecastHandler           = handler.NewGetForecastHandler(getForecastFromCepUseCase)
+	)
+
+	ctx, cancel := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
 	)
+	defer cancel()
+
+	shutdownTracer, err := initProvider(ctx)
+	if err != nil {
+		log.Println("Error initializing provider: ", err)
+		return
+	}
 
-	_, err := initProvider()
+	metricsHandler, shutdownMeter, err := telemetry.InitMeterProvider("service-b")
 	if err != nil {
-		fmt.Println("Error initializing provider: ", err)
+		log.Println("Error initializing meter provider: ", err)
 		return
 	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Get("/", getTemperatureHandler.Handle)
+	r.Use(telemetry.HTTPMetrics)
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+	r.With(render.Middleware(render.Options{})).Get("/", getTemperatureHandler.Handle)
+	r.With(render.Middleware(render.Options{})).Get("/forecast", getForecastHandler.Handle)
+	r.Handle("/metrics", metricsHandler)
+	r.Get("/healthz", health.Live)
+	r.Get("/readyz", readinessChecker().Handler(viper.GetBool("HEALTH_DETAILED")))
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", webServerPort),
+		Handler: r,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Println("Server running at :", webServerPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down gracefully, signal received")
+	case err := <-serverErr:
+		log.Println("Error running server, shutting down: ", err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(
+		context.Background(),
+		viper.GetDuration("SHUTDOWN_GRACE_PERIOD"),
+	)
+	defer shutdownCancel()
+
+	// In-flight requests finish before the providers flush their last
+	// spans and metrics.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error draining connections: ", err)
+	}
+	if err := shutdownTracer(shutdownCtx); err != nil {
+		log.Println("Error shutting down tracer provider: ", err)
+	}
+	if err := shutdownMeter(shutdownCtx); err != nil {
+		log.Println("Error shutting down meter provider: ", err)
+	}
+}
+
+// cepServiceGateway builds the CepService for a comma separated list of
+// providers, tried in order, or hedged when CEP_HEDGE_ENABLED is set.
+func cepServiceGateway(names string) (service.CepService, error) {
+	var providers []service.CepProvider
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		var provider service.CepService
+		switch name {
+		case "":
+			continue
+		case "viacep":
+			provider = service.NewViaCepService(viper.GetString("VIACEP_BASE_URL"), upstreamClient())
+		case "brasilapi":
+			provider = service.NewBrasilApiService(viper.GetString("BRASILAPI_BASE_URL"), upstreamClient())
+		case "opencep":
+			provider = service.NewOpenCepService(viper.GetString("OPENCEP_BASE_URL"), upstreamClient())
+		case "awesomeapi":
+			provider = service.NewAwesomeApiService(viper.GetString("AWESOMEAPI_BASE_URL"), upstreamClient())
+		default:
+			return nil, fmt.Errorf("unknown cep provider %q", name)
+		}
+		if breaker := circuitBreaker(name); breaker != nil {
+			provider = service.NewCircuitBreakerCepService(provider, breaker)
+		}
+
+		providers = append(providers, service.CepProvider{Name: name, Service: provider})
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("no cep provider configured")
+	}
+	if viper.GetBool("CEP_HEDGE_ENABLED") {
+		return service.NewHedgedCepService(service.HedgeOptions{
+			Percentile: viper.GetFloat64("CEP_HEDGE_PERCENTILE"),
+			Window:     viper.GetInt("CEP_HEDGE_WINDOW"),
+			MinDelay:   viper.GetDuration("CEP_HEDGE_MIN_DELAY"),
+			MaxDelay:   viper.GetDuration("CEP_HEDGE_MAX_DELAY"),
+		}, providers...), nil
+	}
+	if len(providers) == 1 {
+		return providers[0].Service, nil
+	}
+
+	return service.NewFailoverCepService(providers...), nil
+}
+
+// circuitBreakers holds the breaker of each upstream, shared by every
+// service calling it.
+var circuitBreakers = map[string]*circuit.Breaker{}
+
+// circuitBreaker returns the breaker of the named upstream, or nil when
+// circuit breaking is disabled.
+func circuitBreaker(name string) *circuit.Breaker {
+	if !viper.GetBool("CIRCUIT_BREAKER_ENABLED") {
+		return nil
+	}
+
+	breaker, ok := circuitBreakers[name]
+	if !ok {
+		breaker = circuit.NewBreaker(name, circuit.Options{
+			FailureThreshold: viper.GetInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD"),
+			OpenTimeout:      viper.GetDuration("CIRCUIT_BREAKER_OPEN_TIMEOUT"),
+			HalfOpenProbes:   viper.GetInt("CIRCUIT_BREAKER_HALF_OPEN_PROBES"),
+		})
+		circuitBreakers[name] = breaker
+	}
+	return breaker
+}
+
+// readinessChecker checks that at least one CEP and one weather provider
+// can be called. Each provider is also reported on its own.
+func readinessChecker() *health.Checker {
+	checker := health.NewChecker(viper.GetDuration("HEALTH_CHECK_TIMEOUT"))
+	checker.Critical("cep", providersCheck(checker, "cep", viper.GetString("CEP_PROVIDERS")))
+	checker.Critical("weather", providersCheck(checker, "weather", viper.GetString("WEATHER_PROVIDERS")))
+	return checker
+}
+
+// keyChecks holds the API key check of each weather provider needing one,
+// made with the provider serving requests.
+var keyChecks = map[string]health.Check{}
+
+// providersCheck adds two optional checks for each of a comma separated
+// list of providers: one passing while its circuit is not open and its API
+// key, when it needs one, is accepted, the other while its base URL is
+// reachable. It returns a check passing when both pass for any of them.
+func providersCheck(checker *health.Checker, kind, names string) health.Check {
+	var checks []health.Check
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		check := circuitCheck(circuitBreaker(name))
+		if keyCheck, ok := keyChecks[name]; ok {
+			check = health.All(check, health.Cached(keyCheck, viper.GetDuration("HEALTH_API_KEY_CHECK_TTL")))
+		}
+		// The probe is remembered so that frequent readiness probes do not
+		// turn into as many upstream requests.
+		reachable := health.Cached(
+			health.Reachable(providerBaseURL(name), viper.GetDuration("HEALTH_PROBE_TIMEOUT")),
+			viper.GetDuration("HEALTH_PROBE_TTL"),
+		)
+
+		checker.Optional(kind+":"+name, check)
+		checker.Optional(kind+":"+name+":reachable", reachable)
+		checks = append(checks, health.All(check, reachable))
+	}
+	return health.Any(checks...)
+}
+
+// providerBaseURL returns the base URL the named provider is called at.
+func providerBaseURL(name string) string {
+	var key, fallback string
+	switch name {
+	case "viacep":
+		key, fallback = "VIACEP_BASE_URL", service.DefaultViaCepBaseURL
+	case "brasilapi":
+		key, fallback = "BRASILAPI_BASE_URL", service.DefaultBrasilApiBaseURL
+	case "opencep":
+		key, fallback = "OPENCEP_BASE_URL", service.DefaultOpenCepBaseURL
+	case "awesomeapi":
+		key, fallback = "AWESOMEAPI_BASE_URL", service.DefaultAwesomeApiBaseURL
+	case "weatherapi":
+		key, fallback = "WEATHER_API_BASE_URL", service.DefaultWeatherApiBaseURL
+	case "openmeteo":
+		key, fallback = "OPENMETEO_BASE_URL", service.DefaultOpenMeteoBaseURL
+	case "openweathermap":
+		key, fallback = "OPENWEATHERMAP_BASE_URL", service.DefaultOpenWeatherMapBaseURL
+	}
+	if baseURL := viper.GetString(key); baseURL != "" {
+		return baseURL
+	}
+	return fallback
+}
+
+// circuitCheck passes while breaker lets calls through, always when nil.
+func circuitCheck(breaker *circuit.Breaker) health.Check {
+	return func(ctx context.Context) error {
+		if breaker != nil && !breaker.Available() {
+			return fmt.Errorf("%s circuit %s", breaker.Name(), breaker.State())
+		}
+		return nil
+	}
+}
+
+// retryBudget is shared by every retrying transport, capping the retries
+// of the whole service.
+var retryBudget *retry.Budget
+
+// upstreamClient returns the client every provider calls its upstream
+// with, retrying with retryTransport and bounding each request, retries
+// included, by UPSTREAM_TIMEOUT.
+func upstreamClient() *http.Client {
+	return &http.Client{Transport: retryTransport(), Timeout: viper.GetDuration("UPSTREAM_TIMEOUT")}
+}
+
+// retryTransport returns the transport retrying upstream requests, or nil
+// when retries are disabled.
+func retryTransport() http.RoundTripper {
+	if !viper.GetBool("RETRY_ENABLED") {
+		return nil
+	}
+
+	if retryBudget == nil {
+		retryBudget = retry.NewBudget(viper.GetFloat64("RETRY_BUDGET_RATIO"), viper.GetInt("RETRY_BUDGET_BURST"))
+	}
+	return retry.NewTransport(nil, retry.Options{
+		MaxAttempts: viper.GetInt("RETRY_MAX_ATTEMPTS"),
+		BaseDelay:   viper.GetDuration("RETRY_BASE_DELAY"),
+		MaxDelay:    viper.GetDuration("RETRY_MAX_DELAY"),
+		Budget:      retryBudget,
+	})
+}
+
+// newGeocoder loads the geocoding table at path, or the embedded one when
+// path is empty.
+func newGeocoder(path string) (*geocoding.Geocoder, error) {
+	if path == "" {
+		return geocoding.NewEmbeddedGeocoder(), nil
+	}
+	return geocoding.NewFileGeocoder(path)
+}
+
+// weatherServiceGateway builds the WeatherService for a comma separated list
+// of providers, tried in order.
+func weatherServiceGateway(names string) (service.WeatherService, error) {
+	var providers []service.WeatherProvider
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		var provider service.WeatherService
+		switch name {
+		case "":
+			continue
+		case "weatherapi":
+			weatherApi := service.NewWeatherApiService(viper.GetString("WEATHER_API_KEY"), viper.GetString("WEATHER_API_BASE_URL"), upstreamClient())
+			keyChecks[name] = weatherApi.CheckKey
+			provider = weatherApi
+		case "openmeteo":
+			provider = service.NewOpenMeteoService(
+				viper.GetString("OPENMETEO_BASE_URL"),
+				viper.GetString("OPENMETEO_GEOCODING_BASE_URL"),
+				upstreamClient(),
+			)
+		case "openweathermap":
+			openWeatherMap := service.NewOpenWeatherMapService(
+				viper.GetString("OPENWEATHERMAP_API_KEY"),
+				viper.GetString("OPENWEATHERMAP_BASE_URL"),
+				upstreamClient(),
+			)
+			keyChecks[name] = openWeatherMap.CheckKey
+			provider = openWeatherMap
+		default:
+			return nil, fmt.Errorf("unknown weather provider %q", name)
+		}
+		if breaker := circuitBreaker(name); breaker != nil {
+			provider = service.NewCircuitBreakerWeatherService(provider, breaker)
+		}
+
+		providers = append(providers, service.WeatherProvider{Name: name, Service: provider})
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("no weather provider configured")
+	}
+	if len(providers) == 1 {
+		return providers[0].Service, nil
+	}
 
-	fmt.Println("Server running at :", webServerPort)
-	port := fmt.Sprintf(":%s", webServerPort)
-	http.ListenAndServe(port, r)
+	return service.NewFailoverWeatherService(providers...), nil
 }
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+
+	c.Set("c", 3, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b, the least recently used entry, to be evicted")
+	}
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Errorf("Expected a=1, got %v %v", value, ok)
+	}
+	if value, ok := c.Get("c"); !ok || value != 3 {
+		t.Errorf("Expected c=3, got %v %v", value, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestLRUExpiration(t *testing.T) {
+	now := time.Now()
+	c := NewLRU[string, int](10)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, time.Minute)
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached before its ttl")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to expire after its ttl")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected expired entry to be removed, got %d entries", c.Len())
+	}
+}
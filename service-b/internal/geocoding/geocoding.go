@@ -0,0 +1,123 @@
+// Package geocoding maps IBGE municipality codes to the coordinates of the
+// municipality. The embedded table only covers the capitals and the largest
+// municipalities; the complete IBGE table must be loaded from a file for
+// every CEP to be geocoded without a provider search.
+package geocoding
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// municipalities is the embedded table of capitals and large
+// municipalities, 86 of the 5,570 of the IBGE table, in the format read by
+// NewGeocoder.
+//
+//go:embed municipalities.csv
+var municipalities string
+
+type Municipality struct {
+	Ibge      string
+	Name      string
+	Uf        string
+	Latitude  float64
+	Longitude float64
+}
+
+// Geocoder looks municipalities up by IBGE code.
+type Geocoder struct {
+	byIbge map[string]Municipality
+}
+
+// NewGeocoder reads a CSV table with the ibge, name, uf, latitude and
+// longitude columns, in any order. Extra columns are ignored.
+func NewGeocoder(r io.Reader) (*Geocoder, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading geocoding header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"ibge", "name", "uf", "latitude", "longitude"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("geocoding table without %s column", name)
+		}
+	}
+
+	g := &Geocoder{byIbge: map[string]Municipality{}}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading geocoding table: %w", err)
+		}
+		if len(record) < len(header) {
+			return nil, fmt.Errorf("geocoding table line %d: expected %d fields, got %d", line, len(header), len(record))
+		}
+
+		latitude, err := strconv.ParseFloat(record[columns["latitude"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geocoding table line %d: %w", line, err)
+		}
+		longitude, err := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geocoding table line %d: %w", line, err)
+		}
+
+		municipality := Municipality{
+			Ibge:      record[columns["ibge"]],
+			Name:      record[columns["name"]],
+			Uf:        strings.ToUpper(record[columns["uf"]]),
+			Latitude:  latitude,
+			Longitude: longitude,
+		}
+		g.byIbge[municipality.Ibge] = municipality
+	}
+
+	return g, nil
+}
+
+// NewEmbeddedGeocoder returns a Geocoder over the embedded table.
+func NewEmbeddedGeocoder() *Geocoder {
+	g, err := NewGeocoder(strings.NewReader(municipalities))
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// NewFileGeocoder returns a Geocoder over the table stored at path.
+func NewFileGeocoder(path string) (*Geocoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewGeocoder(f)
+}
+
+// Lookup returns the municipality with the given IBGE code.
+func (g *Geocoder) Lookup(ibge string) (Municipality, bool) {
+	municipality, ok := g.byIbge[strings.TrimSpace(ibge)]
+	return municipality, ok
+}
+
+// Len returns the number of municipalities known.
+func (g *Geocoder) Len() int {
+	return len(g.byIbge)
+}
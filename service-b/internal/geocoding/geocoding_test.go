@@ -0,0 +1,42 @@
+package geocoding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedGeocoder(t *testing.T) {
+	g := NewEmbeddedGeocoder()
+
+	municipality, ok := g.Lookup("4316907")
+	if !ok {
+		t.Fatal("Expected Santa Maria to be found")
+	}
+	if municipality.Name != "Santa Maria" || municipality.Uf != "RS" {
+		t.Errorf("Expected Santa Maria/RS, got %s/%s", municipality.Name, municipality.Uf)
+	}
+	if municipality.Latitude != -29.6868 || municipality.Longitude != -53.8149 {
+		t.Errorf("Expected -29.6868,-53.8149, got %v,%v", municipality.Latitude, municipality.Longitude)
+	}
+
+	if _, ok := g.Lookup("0000000"); ok {
+		t.Error("Expected unknown IBGE code not to be found")
+	}
+}
+
+func TestNewGeocoder(t *testing.T) {
+	g, err := NewGeocoder(strings.NewReader("codigo_uf,ibge,latitude,longitude,name,uf\n35,3550308,-23.5329,-46.6395,São Paulo,sp\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if municipality, ok := g.Lookup("3550308"); !ok || municipality.Uf != "SP" {
+		t.Errorf("Expected São Paulo/SP, got %+v", municipality)
+	}
+
+	if _, err := NewGeocoder(strings.NewReader("ibge,name\n3550308,São Paulo\n")); err == nil {
+		t.Error("Expected error for a table without coordinates")
+	}
+	if _, err := NewGeocoder(strings.NewReader("ibge,name,uf,latitude,longitude\n3550308,São Paulo,SP,north,-46.6\n")); err == nil {
+		t.Error("Expected error for invalid coordinates")
+	}
+}
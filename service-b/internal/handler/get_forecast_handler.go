@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/usecase"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DefaultForecastDays is the number of days forecast when days is omitted.
+const DefaultForecastDays = 3
+
+type GetForecastHandler struct {
+	getForecastFromCep *usecase.GetForecastFromCepUseCase
+}
+
+type GetForecastHandlerOutput struct {
+	City      string                `json:"city"`
+	Latitude  *float64              `json:"latitude,omitempty"`
+	Longitude *float64              `json:"longitude,omitempty"`
+	Days      []ForecastDayResponse `json:"days"`
+}
+
+type ForecastDayResponse struct {
+	Date          string                 `json:"date"`
+	MinCelsius    float64                `json:"min_temp_C"`
+	MinFahrenheit float64                `json:"min_temp_F"`
+	MinKelvin     float64                `json:"min_temp_K"`
+	MaxCelsius    float64                `json:"max_temp_C"`
+	MaxFahrenheit float64                `json:"max_temp_F"`
+	MaxKelvin     float64                `json:"max_temp_K"`
+	Condition     string                 `json:"condition"`
+	ConditionCode int                    `json:"condition_code"`
+	ChanceOfRain  int                    `json:"chance_of_rain"`
+	Hourly        []ForecastHourResponse `json:"hourly,omitempty"`
+}
+
+type ForecastHourResponse struct {
+	Time          time.Time `json:"time"`
+	Celsius       float64   `json:"temp_C"`
+	Fahrenheit    float64   `json:"temp_F"`
+	Kelvin        float64   `json:"temp_K"`
+	Condition     string    `json:"condition"`
+	ConditionCode int       `json:"condition_code"`
+	ChanceOfRain  int       `json:"chance_of_rain"`
+}
+
+func NewGetForecastHandler(getForecastFromCep *usecase.GetForecastFromCepUseCase) *GetForecastHandler {
+	return &GetForecastHandler{getForecastFromCep: getForecastFromCep}
+}
+
+func (h *GetForecastHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	carrier := propagation.HeaderCarrier(r.Header)
+	ctx := r.Context()
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+	cep, ok := getCep(r)
+	if !ok {
+		problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidZipcode, "invalid zipcode"))
+		return
+	}
+
+	input, err := getForecastInput(r)
+	if err != nil {
+		problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+			WithDetail(err.Error()))
+		return
+	}
+	input.Cep = cep
+
+	output, err := h.getForecastFromCep.Execute(ctx, input)
+	if err != nil {
+		if !errors.Is(err, usecase.CepNotFoundError) {
+			log.Println("Error getting forecast: ", err)
+		}
+		problem.Write(ctx, w, r, errorProblem(err))
+		return
+	}
+
+	response := &GetForecastHandlerOutput{
+		City: output.City,
+		Days: make([]ForecastDayResponse, 0, len(output.Days)),
+	}
+	if output.Coordinates != nil {
+		response.Latitude = &output.Coordinates.Latitude
+		response.Longitude = &output.Coordinates.Longitude
+	}
+	for _, day := range output.Days {
+		dayResponse := ForecastDayResponse{
+			Date:          day.Date,
+			MinCelsius:    day.Min.Celsius,
+			MinFahrenheit: day.Min.Fahrenheit,
+			MinKelvin:     day.Min.Kelvin,
+			MaxCelsius:    day.Max.Celsius,
+			MaxFahrenheit: day.Max.Fahrenheit,
+			MaxKelvin:     day.Max.Kelvin,
+			Condition:     day.Condition,
+			ConditionCode: day.ConditionCode,
+			ChanceOfRain:  day.ChanceOfRain,
+		}
+		for _, hour := range day.Hours {
+			dayResponse.Hourly = append(dayResponse.Hourly, ForecastHourResponse{
+				Time:          hour.Time,
+				Celsius:       hour.Temperature.Celsius,
+				Fahrenheit:    hour.Temperature.Fahrenheit,
+				Kelvin:        hour.Temperature.Kelvin,
+				Condition:     hour.Condition,
+				ConditionCode: hour.ConditionCode,
+				ChanceOfRain:  hour.ChanceOfRain,
+			})
+		}
+		response.Days = append(response.Days, dayResponse)
+	}
+
+	if output.ForecastProvider != "" {
+		w.Header().Set("X-Weather-Provider", output.ForecastProvider)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// getForecastInput reads the days and hourly query parameters.
+func getForecastInput(r *http.Request) (*usecase.GetForecastFromCepInput, error) {
+	input := &usecase.GetForecastFromCepInput{Days: DefaultForecastDays}
+	query := r.URL.Query()
+
+	if raw := query.Get("days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 1 || days > service.MaxForecastDays {
+			return nil, fmt.Errorf("days must be between 1 and %d", service.MaxForecastDays)
+		}
+		input.Days = days
+	}
+
+	if raw := query.Get("hourly"); raw != "" {
+		hourly, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("hourly must be true or false")
+		}
+		input.Hourly = hourly
+	}
+
+	return input, nil
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service/mocks"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/usecase"
+	"go.uber.org/mock/gomock"
+)
+
+func newForecastHandler(t *testing.T, days int) *GetForecastHandler {
+	controller := gomock.NewController(t)
+	cepService := mocks.NewMockCepService(controller)
+	forecastService := mocks.NewMockForecastService(controller)
+	cepService.EXPECT().GetAddressByCep(gomock.Any(), "80010000").Return(&service.ViaCepResponse{
+		Localidade: "Curitiba",
+		Uf:         "PR",
+		Ibge:       "4106902",
+	}, nil).AnyTimes()
+	forecastService.EXPECT().GetForecastByLocation(gomock.Any(), gomock.Any(), days).Return(&service.ForecastResponse{
+		Days: []service.ForecastDay{{
+			Date:      "2024-06-01",
+			MinTemp_c: 10,
+			MinTemp_f: 50,
+			MaxTemp_c: 20,
+			MaxTemp_f: 68,
+			Condition: service.Condition{Text: "Sunny", Code: 1000},
+			Hours:     []service.ForecastHour{{Time: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Temp_c: 12, Temp_f: 53.6}},
+		}},
+		Provider: "weatherapi",
+	}, nil).AnyTimes()
+
+	return NewGetForecastHandler(usecase.NewGetForecastFromCepUseCase(cepService, forecastService))
+}
+
+func TestGetForecastHandler(t *testing.T) {
+	handler := newForecastHandler(t, 5)
+	recorder := httptest.NewRecorder()
+	handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/forecast?cep=80010000&days=5&hourly=true", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+
+	output := &GetForecastHandlerOutput{}
+	if err := json.NewDecoder(recorder.Body).Decode(output); err != nil {
+		t.Fatal(err)
+	}
+	if output.City != "Curitiba" || len(output.Days) != 1 {
+		t.Fatalf("Unexpected forecast %+v", output)
+	}
+
+	day := output.Days[0]
+	if day.MinCelsius != 10 || day.MaxFahrenheit != 68 || day.MaxKelvin != 293.15 {
+		t.Errorf("Unexpected temperatures %+v", day)
+	}
+	if day.Condition != "Sunny" || day.ConditionCode != 1000 {
+		t.Errorf("Expected Sunny, got %s (%d)", day.Condition, day.ConditionCode)
+	}
+	if len(day.Hourly) != 1 || day.Hourly[0].Kelvin != 285.15 {
+		t.Errorf("Expected hourly temperatures, got %+v", day.Hourly)
+	}
+}
+
+func TestGetForecastHandlerDefaults(t *testing.T) {
+	handler := newForecastHandler(t, DefaultForecastDays)
+	recorder := httptest.NewRecorder()
+	handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/forecast?cep=80010000", nil))
+
+	output := &GetForecastHandlerOutput{}
+	if err := json.NewDecoder(recorder.Body).Decode(output); err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Days) != 1 || output.Days[0].Hourly != nil {
+		t.Errorf("Expected daily forecast without hours, got %+v", output.Days)
+	}
+}
+
+func TestGetForecastHandlerInvalidParameters(t *testing.T) {
+	handler := newForecastHandler(t, DefaultForecastDays)
+
+	for _, query := range []string{"cep=123", "cep=80010000&days=0", "cep=80010000&days=15", "cep=80010000&days=two", "cep=80010000&hourly=maybe"} {
+		recorder := httptest.NewRecorder()
+		handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/forecast?"+query, nil))
+
+		if recorder.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected 422 for %s, got %d", query, recorder.Code)
+		}
+	}
+}
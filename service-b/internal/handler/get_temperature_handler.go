@@ -2,9 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"regexp"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/usecase"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
@@ -15,10 +21,20 @@ type GetTemperatureHandler struct {
 }
 
 type GetTemperatureHandlerOutput struct {
-	City       string  `json:"city"`
-	Celsius    float64 `json:"temp_C"`
-	Fahrenheit float64 `json:"temp_F"`
-	Kelvin     float64 `json:"temp_K"`
+	City       string     `json:"city"`
+	Uf         string     `json:"uf,omitempty"`
+	Celsius    float64    `json:"temp_C"`
+	Fahrenheit float64    `json:"temp_F"`
+	Kelvin     float64    `json:"temp_K"`
+	Stale      bool       `json:"stale,omitempty"`
+	ObservedAt *time.Time `json:"observed_at,omitempty"`
+	Latitude   *float64   `json:"latitude,omitempty"`
+	Longitude  *float64   `json:"longitude,omitempty"`
+	// Source names the weather provider that measured the temperature.
+	Source string `json:"source,omitempty"`
+	// Extended current conditions, only set for the groups requested with
+	// the fields query parameter.
+	conditions.Extended
 }
 
 func NewGetTemperatureHandler(getTemperatureFromCep *usecase.GetTemperatureFromCepUseCase) *GetTemperatureHandler {
@@ -30,35 +46,78 @@ func (h *GetTemperatureHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
 
-	cep, ok := h.getCep(r)
+	cep, ok := getCep(r)
 	if !ok {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		w.Write([]byte(`invalid zipcode`))
+		problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidZipcode, "invalid zipcode"))
+		return
+	}
+
+	fields, err := conditions.ParseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		problem.Write(ctx, w, r, problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidParameter, "invalid parameter").
+			WithDetail(err.Error()))
 		return
 	}
 
 	input := &usecase.GetTemperatureFromCepInput{Cep: cep}
 	output, err := h.getTemperatureFromCep.Execute(ctx, input)
 	if err != nil {
-		if err == usecase.CepNotFoundError {
-			w.WriteHeader(http.StatusNotFound)
-			return
+		if !errors.Is(err, usecase.CepNotFoundError) {
+			log.Println("Error getting temperature: ", err)
 		}
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		problem.Write(ctx, w, r, errorProblem(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	if output.CepProvider != "" {
+		w.Header().Set("X-Cep-Provider", output.CepProvider)
+	}
+	if output.WeatherProvider != "" {
+		w.Header().Set("X-Weather-Provider", output.WeatherProvider)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(&GetTemperatureHandlerOutput{
+	w.WriteHeader(http.StatusOK)
+	response := &GetTemperatureHandlerOutput{
 		City:       output.City,
+		Uf:         output.Uf,
 		Celsius:    output.Celsius,
 		Fahrenheit: output.Fahrenheit,
 		Kelvin:     output.Kelvin,
-	})
+		Stale:      output.Stale,
+		Source:     output.WeatherProvider,
+	}
+	if !output.ObservedAt.IsZero() {
+		response.ObservedAt = &output.ObservedAt
+	}
+	if output.Coordinates != nil {
+		response.Latitude = &output.Coordinates.Latitude
+		response.Longitude = &output.Coordinates.Longitude
+	}
+	if output.Conditions != nil && len(fields) > 0 {
+		response.Extended = extendedConditions(output.Conditions).Filter(fields)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func extendedConditions(c *usecase.ConditionsOutput) conditions.Extended {
+	return conditions.Extended{
+		Humidity:      &c.Humidity,
+		WindKph:       &c.WindKph,
+		WindDegree:    &c.WindDegree,
+		WindDir:       c.WindDir,
+		PressureHpa:   &c.PressureHpa,
+		PrecipMm:      &c.PrecipMm,
+		UV:            c.UV,
+		Condition:     c.Condition,
+		ConditionCode: &c.ConditionCode,
+		FeelsLike_C:   &c.FeelsLike.Celsius,
+		FeelsLike_F:   &c.FeelsLike.Fahrenheit,
+		FeelsLike_K:   &c.FeelsLike.Kelvin,
+	}
 }
 
-func (h *GetTemperatureHandler) getCep(r *http.Request) (string, bool) {
+// getCep returns the cep query parameter when it is a valid CEP.
+func getCep(r *http.Request) (string, bool) {
 	cep := r.URL.Query().Get("cep")
 
 	if cep == "" {
@@ -76,3 +135,43 @@ func (h *GetTemperatureHandler) getCep(r *http.Request) (string, bool) {
 
 	return cep, true
 }
+
+// errorProblem maps the usecase errors to the problem returned to clients.
+func errorProblem(err error) *problem.Problem {
+	var p *problem.Problem
+	switch {
+	case errors.Is(err, usecase.CepNotFoundError):
+		p = problem.New(http.StatusNotFound, problem.CodeZipcodeNotFound, "can not find zipcode")
+	case errors.Is(err, usecase.LocationNotFoundError):
+		p = problem.New(http.StatusNotFound, problem.CodeLocationNotFound, "can not find weather for zipcode")
+	case errors.Is(err, usecase.NoCepProviderAvailableError):
+		p = problem.New(http.StatusServiceUnavailable, problem.CodeUpstreamUnavailable, "no cep provider available")
+	case errors.Is(err, usecase.NoWeatherProviderAvailableError):
+		p = problem.New(http.StatusServiceUnavailable, problem.CodeUpstreamUnavailable, "no weather provider available")
+	case errors.Is(err, usecase.UpstreamTimeoutError):
+		p = problem.New(http.StatusGatewayTimeout, problem.CodeUpstreamTimeout, "upstream provider timed out")
+	case errors.Is(err, usecase.UpstreamRateLimitedError):
+		p = problem.New(http.StatusServiceUnavailable, problem.CodeUpstreamRateLimited, "upstream provider rate limited")
+	case errors.Is(err, usecase.UpstreamAuthError):
+		p = problem.New(http.StatusBadGateway, problem.CodeUpstreamAuth, "upstream provider rejected credentials")
+	case errors.Is(err, usecase.UpstreamBadPayloadError):
+		p = problem.New(http.StatusBadGateway, problem.CodeUpstreamBadPayload, "upstream provider returned an invalid payload")
+	case errors.Is(err, usecase.UpstreamUnavailableError):
+		p = problem.New(http.StatusBadGateway, problem.CodeUpstreamUnavailable, "upstream provider unavailable")
+	case errors.Is(err, usecase.CircuitOpenError):
+		p = problem.New(http.StatusServiceUnavailable, problem.CodeCircuitOpen, "upstream provider circuit open")
+	default:
+		return problem.New(http.StatusInternalServerError, problem.CodeInternal, "internal error")
+	}
+
+	var upstreamErr *service.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		upstream := &problem.Upstream{Name: upstreamErr.Upstream, Status: upstreamErr.StatusCode}
+		if upstreamErr.Err != nil {
+			upstream.Detail = upstreamErr.Err.Error()
+		}
+		p.WithUpstream(upstream)
+	}
+
+	return p
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/problem"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service/mocks"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/usecase"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetTemperatureHandlerErrors(t *testing.T) {
+	expected := map[error]int{
+		service.CepNotFoundError: http.StatusNotFound,
+		&service.UpstreamError{Upstream: "viacep", Kind: service.UpstreamTimeoutError}:     http.StatusGatewayTimeout,
+		&service.UpstreamError{Upstream: "viacep", Kind: service.UpstreamRateLimitedError}: http.StatusServiceUnavailable,
+		&service.UpstreamError{Upstream: "viacep", Kind: service.UpstreamAuthError}:        http.StatusBadGateway,
+		&service.UpstreamError{Upstream: "viacep", Kind: service.UpstreamUnavailableError}: http.StatusBadGateway,
+		&service.UpstreamError{Upstream: "viacep", Kind: service.UpstreamBadPayloadError}:  http.StatusBadGateway,
+		&service.UpstreamError{Upstream: "viacep", Kind: service.CircuitOpenError}:         http.StatusServiceUnavailable,
+	}
+
+	for err, status := range expected {
+		controller := gomock.NewController(t)
+		cepService := mocks.NewMockCepService(controller)
+		weatherService := mocks.NewMockWeatherService(controller)
+		cepService.EXPECT().GetAddressByCep(gomock.Any(), "01001000").Return(nil, err)
+
+		handler := NewGetTemperatureHandler(usecase.NewGetTemperatureFromCepUseCase(cepService, weatherService))
+		recorder := httptest.NewRecorder()
+		handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/?cep=01001000", nil))
+
+		if recorder.Code != status {
+			t.Errorf("Expected %d for %v, got %d", status, err, recorder.Code)
+		}
+	}
+}
+
+func TestGetTemperatureHandlerInvalidCep(t *testing.T) {
+	controller := gomock.NewController(t)
+	handler := NewGetTemperatureHandler(usecase.NewGetTemperatureFromCepUseCase(
+		mocks.NewMockCepService(controller),
+		mocks.NewMockWeatherService(controller),
+	))
+
+	recorder := httptest.NewRecorder()
+	handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/?cep=123", nil))
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", recorder.Code)
+	}
+}
+
+func TestGetTemperatureHandlerProblemBody(t *testing.T) {
+	controller := gomock.NewController(t)
+	cepService := mocks.NewMockCepService(controller)
+	cepService.EXPECT().GetAddressByCep(gomock.Any(), "00000000").Return(nil, service.CepNotFoundError)
+
+	handler := NewGetTemperatureHandler(usecase.NewGetTemperatureFromCepUseCase(cepService, mocks.NewMockWeatherService(controller)))
+	recorder := httptest.NewRecorder()
+	handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/?cep=00000000", nil))
+
+	p := problem.Parse(recorder.Result())
+	if p.Status != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", p.Status)
+	}
+	if p.Title != "can not find zipcode" {
+		t.Errorf("Expected can not find zipcode, got %s", p.Title)
+	}
+	if p.Code != problem.CodeZipcodeNotFound {
+		t.Errorf("Expected %s, got %s", problem.CodeZipcodeNotFound, p.Code)
+	}
+}
+
+func TestGetTemperatureHandlerCoordinates(t *testing.T) {
+	controller := gomock.NewController(t)
+	cepService := mocks.NewMockCepService(controller)
+	weatherService := mocks.NewMockWeatherService(controller)
+	coordinates := &service.Coordinates{Latitude: -29.6868, Longitude: -53.8149}
+	cepService.EXPECT().GetAddressByCep(gomock.Any(), "97010000").Return(&service.ViaCepResponse{
+		Localidade:  "Santa Maria",
+		Uf:          "RS",
+		Ibge:        "4316907",
+		Coordinates: coordinates,
+	}, nil)
+	weatherService.EXPECT().GetWeatherByLocation(gomock.Any(), gomock.Any()).Return(&service.WeatherResponse{Temp_c: 14}, nil)
+
+	handler := NewGetTemperatureHandler(usecase.NewGetTemperatureFromCepUseCase(cepService, weatherService))
+	recorder := httptest.NewRecorder()
+	handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/?cep=97010000", nil))
+
+	output := &GetTemperatureHandlerOutput{}
+	if err := json.NewDecoder(recorder.Body).Decode(output); err != nil {
+		t.Fatal(err)
+	}
+	if output.Latitude == nil || *output.Latitude != -29.6868 || output.Longitude == nil || *output.Longitude != -53.8149 {
+		t.Errorf("Expected -29.6868,-53.8149, got %v,%v", output.Latitude, output.Longitude)
+	}
+}
+
+func TestGetTemperatureHandlerFields(t *testing.T) {
+	uv := 5.0
+	newHandler := func() *GetTemperatureHandler {
+		controller := gomock.NewController(t)
+		cepService := mocks.NewMockCepService(controller)
+		weatherService := mocks.NewMockWeatherService(controller)
+		cepService.EXPECT().GetAddressByCep(gomock.Any(), "01001000").Return(&service.ViaCepResponse{Localidade: "São Paulo", Uf: "SP"}, nil)
+		weatherService.EXPECT().GetWeatherByLocation(gomock.Any(), gomock.Any()).Return(&service.WeatherResponse{
+			Temp_c: 20,
+			Temp_f: 68,
+			Conditions: &service.Conditions{
+				Humidity:    60,
+				WindKph:     12.6,
+				WindDegree:  140,
+				WindDir:     "SE",
+				PressureHpa: 1018,
+				UV:          &uv,
+				Condition:   service.Condition{Text: "Sunny", Code: 1000},
+				FeelsLike_c: 21,
+				FeelsLike_f: 69.8,
+			},
+		}, nil)
+		return NewGetTemperatureHandler(usecase.NewGetTemperatureFromCepUseCase(cepService, weatherService))
+	}
+
+	expected := map[string]string{
+		"":                          `{"city":"São Paulo","uf":"SP","temp_C":20,"temp_F":68,"temp_K":293.15}`,
+		"&fields=wind,condition":    `{"city":"São Paulo","uf":"SP","temp_C":20,"temp_F":68,"temp_K":293.15,"wind_kph":12.6,"wind_degree":140,"wind_dir":"SE","condition":"Sunny","condition_code":1000}`,
+		"&fields=feels_like,uv":     `{"city":"São Paulo","uf":"SP","temp_C":20,"temp_F":68,"temp_K":293.15,"uv":5,"feels_like_C":21,"feels_like_F":69.8,"feels_like_K":294.15}`,
+		"&fields=humidity,pressure": `{"city":"São Paulo","uf":"SP","temp_C":20,"temp_F":68,"temp_K":293.15,"humidity":60,"pressure_hpa":1018}`,
+	}
+
+	for query, body := range expected {
+		recorder := httptest.NewRecorder()
+		newHandler().Handle(recorder, httptest.NewRequest(http.MethodGet, "/?cep=01001000"+query, nil))
+
+		if got := strings.TrimSpace(recorder.Body.String()); got != body {
+			t.Errorf("Expected %s for %q, got %s", body, query, got)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler := NewGetTemperatureHandler(usecase.NewGetTemperatureFromCepUseCase(mocks.NewMockCepService(gomock.NewController(t)), nil))
+	handler.Handle(recorder, httptest.NewRequest(http.MethodGet, "/?cep=01001000&fields=visibility", nil))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an unknown field, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+)
+
+const DefaultAwesomeApiBaseURL = "https://cep.awesomeapi.com.br"
+
+type AwesomeApiResponse struct {
+	Cep      string `json:"cep"`
+	Address  string `json:"address"`
+	State    string `json:"state"`
+	District string `json:"district"`
+	City     string `json:"city"`
+	CityIbge string `json:"city_ibge"`
+	Ddd      string `json:"ddd"`
+	Lat      string `json:"lat"`
+	Lng      string `json:"lng"`
+}
+
+// AwesomeApiService looks up addresses with the AwesomeAPI CEP endpoint.
+type AwesomeApiService struct {
+	baseURL string
+	client  *http.Client
+	logger  *log.Logger
+}
+
+func NewAwesomeApiService(baseURL string, client *http.Client) *AwesomeApiService {
+	if baseURL == "" {
+		baseURL = DefaultAwesomeApiBaseURL
+	}
+
+	return &AwesomeApiService{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  upstreamClient(client),
+		logger:  log.New(os.Stdout, "AwesomeApiService: ", log.LstdFlags),
+	}
+}
+
+func (a *AwesomeApiService) GetAddressByCep(ctx context.Context, cep string) (_ *ViaCepResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - AwesomeAPI")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() { telemetry.RecordUpstreamCall(ctx, "awesomeapi", outcome, start) }()
+
+	cep = strings.ReplaceAll(cep, "-", "")
+	url := a.baseURL + "/json/" + cep
+
+	a.logger.Println("Requesting data from AwesomeAPI: ", url)
+	response := &AwesomeApiResponse{}
+	notFound, err := getJSON(ctx, a.client, "awesomeapi", CepServiceError, url, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if notFound || response.Cep == "" {
+		outcome = telemetry.OutcomeNotFound
+		return nil, CepNotFoundError
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	address := &ViaCepResponse{
+		Cep:        response.Cep,
+		Logradouro: response.Address,
+		Bairro:     response.District,
+		Localidade: response.City,
+		Uf:         response.State,
+		Ibge:       response.CityIbge,
+		Ddd:        response.Ddd,
+		Provider:   "awesomeapi",
+	}
+
+	latitude, latErr := strconv.ParseFloat(response.Lat, 64)
+	longitude, lngErr := strconv.ParseFloat(response.Lng, 64)
+	if latErr == nil && lngErr == nil {
+		address.Coordinates = &Coordinates{Latitude: latitude, Longitude: longitude}
+	}
+	return address, nil
+}
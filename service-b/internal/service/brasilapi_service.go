@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+)
+
+const DefaultBrasilApiBaseURL = "https://brasilapi.com.br"
+
+type BrasilApiResponse struct {
+	Cep          string `json:"cep"`
+	State        string `json:"state"`
+	City         string `json:"city"`
+	Neighborhood string `json:"neighborhood"`
+	Street       string `json:"street"`
+}
+
+// BrasilApiService looks up addresses with the BrasilAPI CEP v1 endpoint.
+type BrasilApiService struct {
+	baseURL string
+	client  *http.Client
+	logger  *log.Logger
+}
+
+func NewBrasilApiService(baseURL string, client *http.Client) *BrasilApiService {
+	if baseURL == "" {
+		baseURL = DefaultBrasilApiBaseURL
+	}
+
+	return &BrasilApiService{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  upstreamClient(client),
+		logger:  log.New(os.Stdout, "BrasilApiService: ", log.LstdFlags),
+	}
+}
+
+func (b *BrasilApiService) GetAddressByCep(ctx context.Context, cep string) (_ *ViaCepResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - BrasilAPI")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() { telemetry.RecordUpstreamCall(ctx, "brasilapi", outcome, start) }()
+
+	cep = strings.ReplaceAll(cep, "-", "")
+	url := b.baseURL + "/api/cep/v1/" + cep
+
+	b.logger.Println("Requesting data from BrasilAPI: ", url)
+	response := &BrasilApiResponse{}
+	notFound, err := getJSON(ctx, b.client, "brasilapi", CepServiceError, url, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if notFound || response.Cep == "" {
+		outcome = telemetry.OutcomeNotFound
+		return nil, CepNotFoundError
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	return &ViaCepResponse{
+		Cep:        response.Cep,
+		Logradouro: response.Street,
+		Bairro:     response.Neighborhood,
+		Localidade: response.City,
+		Uf:         response.State,
+		Provider:   "brasilapi",
+	}, nil
+}
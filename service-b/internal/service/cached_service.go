@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type CacheOptions struct {
+	TTL time.Duration
+	// NegativeTTL is how long not found answers are kept, zero disables
+	// negative caching.
+	NegativeTTL time.Duration
+	Size        int
+}
+
+type cepCacheEntry struct {
+	address  *ViaCepResponse
+	notFound bool
+}
+
+// CachedCepService caches the addresses, and the not found answers, of the
+// wrapped CepService.
+type CachedCepService struct {
+	next  CepService
+	opts  CacheOptions
+	cache *cache.LRU[string, cepCacheEntry]
+}
+
+func NewCachedCepService(next CepService, opts CacheOptions) *CachedCepService {
+	return &CachedCepService{
+		next:  next,
+		opts:  opts,
+		cache: cache.NewLRU[string, cepCacheEntry](opts.Size),
+	}
+}
+
+func (c *CachedCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - Cache")
+	defer span.End()
+
+	cep = strings.ReplaceAll(cep, "-", "")
+	if entry, ok := c.cache.Get(cep); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true), attribute.Bool("cache.negative", entry.notFound))
+		telemetry.RecordCacheLookup(ctx, "cep", true)
+		if entry.notFound {
+			return nil, CepNotFoundError
+		}
+		address := *entry.address
+		return &address, nil
+	}
+
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	telemetry.RecordCacheLookup(ctx, "cep", false)
+
+	address, err := c.next.GetAddressByCep(ctx, cep)
+	if errors.Is(err, CepNotFoundError) && c.opts.NegativeTTL > 0 {
+		c.cache.Set(cep, cepCacheEntry{notFound: true}, c.opts.NegativeTTL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *address
+	c.cache.Set(cep, cepCacheEntry{address: &cached}, c.opts.TTL)
+	return address, nil
+}
+
+// CachedWeatherService caches the current weather of each location returned
+// by the wrapped WeatherService.
+type CachedWeatherService struct {
+	next  WeatherService
+	opts  CacheOptions
+	cache *cache.LRU[string, WeatherResponse]
+}
+
+func NewCachedWeatherService(next WeatherService, opts CacheOptions) *CachedWeatherService {
+	return &CachedWeatherService{
+		next:  next,
+		opts:  opts,
+		cache: cache.NewLRU[string, WeatherResponse](opts.Size),
+	}
+}
+
+func (c *CachedWeatherService) GetWeatherByLocation(ctx context.Context, location Location) (*WeatherResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - Cache")
+	defer span.End()
+
+	key := location.Key()
+	if weather, ok := c.cache.Get(key); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		telemetry.RecordCacheLookup(ctx, "weather", true)
+		return &weather, nil
+	}
+
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	telemetry.RecordCacheLookup(ctx, "weather", false)
+
+	weather, err := c.next.GetWeatherByLocation(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stale readings are fallbacks for a failing provider, the next request
+	// must try it again.
+	if !weather.Stale {
+		c.cache.Set(key, *weather, c.opts.TTL)
+	}
+	return weather, nil
+}
+
+// CachedForecastService caches the forecast of each location and number of
+// days returned by the wrapped ForecastService.
+type CachedForecastService struct {
+	next  ForecastService
+	opts  CacheOptions
+	cache *cache.LRU[string, ForecastResponse]
+}
+
+func NewCachedForecastService(next ForecastService, opts CacheOptions) *CachedForecastService {
+	return &CachedForecastService{
+		next:  next,
+		opts:  opts,
+		cache: cache.NewLRU[string, ForecastResponse](opts.Size),
+	}
+}
+
+func (c *CachedForecastService) GetForecastByLocation(ctx context.Context, location Location, days int) (*ForecastResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetForecastByLocation - Cache")
+	defer span.End()
+
+	key := fmt.Sprintf("%s/%d", location.Key(), days)
+	if forecast, ok := c.cache.Get(key); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		telemetry.RecordCacheLookup(ctx, "forecast", true)
+		return &forecast, nil
+	}
+
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	telemetry.RecordCacheLookup(ctx, "forecast", false)
+
+	forecast, err := c.next.GetForecastByLocation(ctx, location, days)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, *forecast, c.opts.TTL)
+	return forecast, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCepService struct {
+	calls   int
+	address *ViaCepResponse
+	err     error
+}
+
+func (f *fakeCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+	f.calls++
+	return f.address, f.err
+}
+
+type fakeWeatherService struct {
+	mu      sync.Mutex
+	calls   int
+	weather *WeatherResponse
+	err     error
+}
+
+func (f *fakeWeatherService) GetWeatherByLocation(ctx context.Context, location Location) (*WeatherResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	return f.weather, f.err
+}
+
+func (f *fakeWeatherService) set(weather *WeatherResponse, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.weather, f.err = weather, err
+}
+
+func TestCachedCepService(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeCepService{address: &ViaCepResponse{Cep: "01001-000", Localidade: "São Paulo"}}
+	service := NewCachedCepService(next, CacheOptions{TTL: time.Hour, NegativeTTL: time.Hour, Size: 10})
+
+	for i := 0; i < 3; i++ {
+		address, err := service.GetAddressByCep(ctx, "01001000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address.Localidade != "São Paulo" {
+			t.Errorf("Expected São Paulo, got %s", address.Localidade)
+		}
+	}
+
+	if next.calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", next.calls)
+	}
+}
+
+func TestCachedCepServiceNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeCepService{err: CepNotFoundError}
+	service := NewCachedCepService(next, CacheOptions{TTL: time.Hour, NegativeTTL: time.Hour, Size: 10})
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+			t.Fatalf("Expected CepNotFoundError, got %v", err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", next.calls)
+	}
+
+	next.err = CepServiceError
+	service = NewCachedCepService(next, CacheOptions{TTL: time.Hour, NegativeTTL: time.Hour, Size: 10})
+	service.GetAddressByCep(ctx, "01001000")
+	service.GetAddressByCep(ctx, "01001000")
+	if next.calls != 3 {
+		t.Errorf("Expected upstream errors not to be cached, got %d calls", next.calls)
+	}
+
+	// Not found answers wrapped by the providers are cached too.
+	next.err = fmt.Errorf("viacep: %w", CepNotFoundError)
+	service = NewCachedCepService(next, CacheOptions{TTL: time.Hour, NegativeTTL: time.Hour, Size: 10})
+	for i := 0; i < 2; i++ {
+		if _, err := service.GetAddressByCep(ctx, "00000000"); !errors.Is(err, CepNotFoundError) {
+			t.Fatalf("Expected CepNotFoundError, got %v", err)
+		}
+	}
+	if next.calls != 4 {
+		t.Errorf("Expected wrapped not found answers to be cached, got %d calls", next.calls)
+	}
+}
+
+func TestCachedWeatherService(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeWeatherService{weather: &WeatherResponse{Name: "São Paulo", Temp_c: 25}}
+	service := NewCachedWeatherService(next, CacheOptions{TTL: time.Hour, Size: 10})
+
+	service.GetWeatherByLocation(ctx, Location{City: "São Paulo", Uf: "SP"})
+	weather, err := service.GetWeatherByLocation(ctx, Location{City: "Sao Paulo", Uf: "SP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Temp_c != 25 {
+		t.Errorf("Expected 25, got %v", weather.Temp_c)
+	}
+	if next.calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", next.calls)
+	}
+}
+
+type fakeForecastService struct {
+	calls    int
+	forecast *ForecastResponse
+	err      error
+}
+
+func (f *fakeForecastService) GetForecastByLocation(ctx context.Context, location Location, days int) (*ForecastResponse, error) {
+	f.calls++
+	return f.forecast, f.err
+}
+
+func TestCachedForecastService(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeForecastService{forecast: &ForecastResponse{Name: "Curitiba", Days: []ForecastDay{{Date: "2024-06-01"}}}}
+	service := NewCachedForecastService(next, CacheOptions{TTL: time.Hour, Size: 10})
+	curitiba := Location{City: "Curitiba", Uf: "PR", Ibge: "4106902"}
+
+	service.GetForecastByLocation(ctx, curitiba, 3)
+	forecast, err := service.GetForecastByLocation(ctx, curitiba, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast.Days) != 1 {
+		t.Errorf("Expected cached forecast, got %+v", forecast)
+	}
+	if next.calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", next.calls)
+	}
+
+	service.GetForecastByLocation(ctx, curitiba, 5)
+	if next.calls != 2 {
+		t.Errorf("Expected forecasts of different lengths to be cached apart, got %d calls", next.calls)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newCepProviderServer(t *testing.T, path, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case path:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCepProviders(t *testing.T) {
+	ctx := context.Background()
+
+	brasilApi := newCepProviderServer(t, "/api/cep/v1/01001000",
+		`{"cep":"01001000","state":"SP","city":"São Paulo","neighborhood":"Sé","street":"Praça da Sé","service":"open-cep"}`)
+	openCep := newCepProviderServer(t, "/v1/01001000",
+		`{"cep":"01001-000","logradouro":"Praça da Sé","complemento":"lado ímpar","bairro":"Sé","localidade":"São Paulo","uf":"SP","ibge":"3550308"}`)
+	awesomeApi := newCepProviderServer(t, "/json/01001000",
+		`{"cep":"01001000","address_type":"Praça","address_name":"da Sé","address":"Praça da Sé","state":"SP","district":"Sé","lat":"-23.5503","lng":"-46.6342","city":"São Paulo","city_ibge":"3550308","ddd":"11"}`)
+
+	providers := map[string]CepService{
+		"brasilapi":  NewBrasilApiService(brasilApi.URL, nil),
+		"opencep":    NewOpenCepService(openCep.URL, nil),
+		"awesomeapi": NewAwesomeApiService(awesomeApi.URL, nil),
+	}
+
+	for name, provider := range providers {
+		address, err := provider.GetAddressByCep(ctx, "01001-000")
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if address.Localidade != "São Paulo" || address.Uf != "SP" {
+			t.Errorf("%s: expected São Paulo/SP, got %s/%s", name, address.Localidade, address.Uf)
+		}
+		if address.Provider != name {
+			t.Errorf("%s: expected provider %s, got %s", name, name, address.Provider)
+		}
+
+		if _, err := provider.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+			t.Errorf("%s: expected CepNotFoundError, got %v", name, err)
+		}
+	}
+}
+
+func TestViaCepService(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws/01001000/json" {
+			w.Write([]byte(`{"erro": true}`))
+			return
+		}
+		w.Write([]byte(`{"cep":"01001-000","logradouro":"Praça da Sé","bairro":"Sé","localidade":"São Paulo","uf":"SP","ibge":"3550308"}`))
+	}))
+	defer server.Close()
+
+	service := NewViaCepService(server.URL+"/", nil)
+	address, err := service.GetAddressByCep(ctx, "01001-000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Localidade != "São Paulo" || address.Provider != "viacep" {
+		t.Errorf("Expected São Paulo from viacep, got %s from %s", address.Localidade, address.Provider)
+	}
+
+	if _, err := service.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+		t.Errorf("Expected CepNotFoundError, got %v", err)
+	}
+}
+
+func TestCepProvidersUpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	for _, provider := range []CepService{
+		NewBrasilApiService(server.URL, nil),
+		NewOpenCepService(server.URL, nil),
+		NewAwesomeApiService(server.URL, nil),
+	} {
+		_, err := provider.GetAddressByCep(context.Background(), "01001000")
+		if !errors.Is(err, UpstreamUnavailableError) {
+			t.Errorf("Expected UpstreamUnavailableError, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/circuit"
+	"go.opentelemetry.io/otel"
+)
+
+// CircuitBreakerCepService fails calls to the wrapped CepService fast while
+// its circuit is open. Not found answers count as successes.
+type CircuitBreakerCepService struct {
+	next    CepService
+	breaker *circuit.Breaker
+}
+
+func NewCircuitBreakerCepService(next CepService, breaker *circuit.Breaker) *CircuitBreakerCepService {
+	return &CircuitBreakerCepService{next: next, breaker: breaker}
+}
+
+func (c *CircuitBreakerCepService) GetAddressByCep(ctx context.Context, cep string) (_ *ViaCepResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - CircuitBreaker")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	done, err := c.breaker.Allow(ctx)
+	if err != nil {
+		return nil, circuitOpenError(c.breaker.Name(), CepServiceError)
+	}
+
+	address, err := c.next.GetAddressByCep(ctx, cep)
+	done(circuitOutcome(err))
+	return address, err
+}
+
+// Available reports whether the circuit lets calls through, letting
+// FailoverCepService skip the provider.
+func (c *CircuitBreakerCepService) Available() bool {
+	return c.breaker.Available()
+}
+
+// CircuitBreakerWeatherService fails calls to the wrapped WeatherService
+// fast while its circuit is open. Unknown locations count as successes.
+type CircuitBreakerWeatherService struct {
+	next    WeatherService
+	breaker *circuit.Breaker
+}
+
+func NewCircuitBreakerWeatherService(next WeatherService, breaker *circuit.Breaker) *CircuitBreakerWeatherService {
+	return &CircuitBreakerWeatherService{next: next, breaker: breaker}
+}
+
+func (c *CircuitBreakerWeatherService) GetWeatherByLocation(ctx context.Context, location Location) (_ *WeatherResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - CircuitBreaker")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	done, err := c.breaker.Allow(ctx)
+	if err != nil {
+		return nil, circuitOpenError(c.breaker.Name(), WeatherServiceError)
+	}
+
+	weather, err := c.next.GetWeatherByLocation(ctx, location)
+	done(circuitOutcome(err))
+	return weather, err
+}
+
+// Available reports whether the circuit lets calls through, letting
+// FailoverWeatherService skip the provider.
+func (c *CircuitBreakerWeatherService) Available() bool {
+	return c.breaker.Available()
+}
+
+// CircuitBreakerForecastService fails calls to the wrapped ForecastService
+// fast while its circuit is open.
+type CircuitBreakerForecastService struct {
+	next    ForecastService
+	breaker *circuit.Breaker
+}
+
+func NewCircuitBreakerForecastService(next ForecastService, breaker *circuit.Breaker) *CircuitBreakerForecastService {
+	return &CircuitBreakerForecastService{next: next, breaker: breaker}
+}
+
+func (c *CircuitBreakerForecastService) GetForecastByLocation(ctx context.Context, location Location, days int) (_ *ForecastResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetForecastByLocation - CircuitBreaker")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	done, err := c.breaker.Allow(ctx)
+	if err != nil {
+		return nil, circuitOpenError(c.breaker.Name(), WeatherServiceError)
+	}
+
+	forecast, err := c.next.GetForecastByLocation(ctx, location, days)
+	done(circuitOutcome(err))
+	return forecast, err
+}
+
+// circuitOpenError is the fast failure of a call rejected by the circuit of
+// upstream.
+func circuitOpenError(upstream string, provider error) error {
+	return &UpstreamError{Upstream: upstream, Kind: CircuitOpenError, Provider: provider, Err: circuit.OpenError}
+}
+
+// circuitOutcome returns the outcome of a call failing with err. Answers
+// the upstream gave, like not found, are successes. Calls cancelled by the
+// caller, e.g. the losing call of a hedged lookup, are ignored: they say
+// nothing of the upstream.
+func circuitOutcome(err error) circuit.Outcome {
+	switch {
+	case err == nil, errors.Is(err, CepNotFoundError), errors.Is(err, LocationNotFoundError):
+		return circuit.Success
+	case errors.Is(err, context.Canceled):
+		return circuit.Ignored
+	default:
+		return circuit.Failure
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/circuit"
+)
+
+func TestCircuitBreakerCepService(t *testing.T) {
+	next := &fakeCepService{err: CepNotFoundError}
+	service := NewCircuitBreakerCepService(next, circuit.NewBreaker("test-cep", circuit.Options{FailureThreshold: 2, OpenTimeout: time.Hour}))
+
+	for i := 0; i < 3; i++ {
+		service.GetAddressByCep(context.Background(), "00000000")
+	}
+	if !service.Available() {
+		t.Fatal("Expected not found answers to keep the circuit closed")
+	}
+
+	next.err = statusError("viacep", CepServiceError, 500)
+	service.GetAddressByCep(context.Background(), "01001000")
+	service.GetAddressByCep(context.Background(), "01001000")
+	if service.Available() {
+		t.Fatal("Expected the circuit to open")
+	}
+
+	calls := next.calls
+	_, err := service.GetAddressByCep(context.Background(), "01001000")
+	if !errors.Is(err, CircuitOpenError) || !errors.Is(err, CepServiceError) {
+		t.Errorf("Expected CircuitOpenError, got %v", err)
+	}
+	if next.calls != calls {
+		t.Errorf("Expected the open circuit to fail fast, got %d calls", next.calls-calls)
+	}
+}
+
+func TestCircuitBreakerSkippedByFailover(t *testing.T) {
+	breaker := circuit.NewBreaker("test-weather", circuit.Options{FailureThreshold: 1, OpenTimeout: time.Hour})
+	failing := &fakeWeatherService{err: statusError("weatherapi", WeatherServiceError, 502)}
+	fallback := &fakeWeatherService{weather: &WeatherResponse{Temp_c: 20}}
+
+	service := NewFailoverWeatherService(
+		WeatherProvider{Name: "weatherapi", Service: NewCircuitBreakerWeatherService(failing, breaker)},
+		WeatherProvider{Name: "openmeteo", Service: fallback},
+	)
+
+	for i := 0; i < 3; i++ {
+		weather, err := service.GetWeatherByLocation(context.Background(), saoPaulo)
+		if err != nil || weather.Temp_c != 20 {
+			t.Fatalf("Expected the fallback provider to answer, got %v, %v", weather, err)
+		}
+	}
+	if failing.calls != 1 {
+		t.Errorf("Expected the open provider to be skipped, got %d calls", failing.calls)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelledCalls(t *testing.T) {
+	next := &fakeCepService{}
+	service := NewCircuitBreakerCepService(next, circuit.NewBreaker("test-cancelled", circuit.Options{FailureThreshold: 2, OpenTimeout: time.Hour}))
+
+	// The losing calls of hedged lookups are cancelled between failures.
+	for _, err := range []error{statusError("viacep", CepServiceError, 500), context.Canceled, statusError("viacep", CepServiceError, 500)} {
+		next.err = err
+		service.GetAddressByCep(context.Background(), "01001000")
+	}
+	if service.Available() {
+		t.Error("Expected cancelled calls not to reset the failures")
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	UpstreamUnavailableError = errors.New("upstream unavailable")
+	UpstreamTimeoutError     = errors.New("upstream timeout")
+	UpstreamAuthError        = errors.New("upstream authentication failed")
+	UpstreamRateLimitedError = errors.New("upstream rate limited")
+	UpstreamBadPayloadError  = errors.New("upstream returned an invalid payload")
+	LocationNotFoundError    = errors.New("location not found")
+	// CircuitOpenError is returned without calling an upstream whose
+	// circuit breaker is open.
+	CircuitOpenError = errors.New("upstream circuit open")
+)
+
+// UpstreamError describes a failed call to an upstream provider. It matches
+// its Kind, the provider error (e.g. CepServiceError) and the underlying
+// cause with errors.Is.
+type UpstreamError struct {
+	Upstream   string
+	StatusCode int
+	Kind       error
+	Provider   error
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Upstream, e.Kind)
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	errs := []error{e.Kind}
+	if e.Provider != nil {
+		errs = append(errs, e.Provider)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// statusError classifies a non successful upstream status code.
+func statusError(upstream string, provider error, statusCode int) error {
+	var kind error
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		kind = UpstreamAuthError
+	case statusCode == http.StatusTooManyRequests:
+		kind = UpstreamRateLimitedError
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		kind = UpstreamTimeoutError
+	default:
+		kind = UpstreamUnavailableError
+	}
+
+	return &UpstreamError{Upstream: upstream, StatusCode: statusCode, Kind: kind, Provider: provider}
+}
+
+// transportError classifies an error returned by http.Client.Do.
+func transportError(upstream string, provider error, err error) error {
+	kind := UpstreamUnavailableError
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = UpstreamTimeoutError
+	}
+
+	return &UpstreamError{Upstream: upstream, Kind: kind, Provider: provider, Err: err}
+}
+
+// payloadError wraps a response body that could not be read or decoded.
+func payloadError(upstream string, provider error, err error) error {
+	return &UpstreamError{Upstream: upstream, Kind: UpstreamBadPayloadError, Provider: provider, Err: err}
+}
+
+// recordError records err on span. Not found answers are expected outcomes
+// and keep the span status unset.
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	if !errors.Is(err, CepNotFoundError) && !errors.Is(err, LocationNotFoundError) {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
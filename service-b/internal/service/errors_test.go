@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStatusError(t *testing.T) {
+	expected := map[int]error{
+		http.StatusUnauthorized:        UpstreamAuthError,
+		http.StatusForbidden:           UpstreamAuthError,
+		http.StatusTooManyRequests:     UpstreamRateLimitedError,
+		http.StatusGatewayTimeout:      UpstreamTimeoutError,
+		http.StatusInternalServerError: UpstreamUnavailableError,
+		http.StatusServiceUnavailable:  UpstreamUnavailableError,
+	}
+
+	for statusCode, kind := range expected {
+		err := statusError("viacep", CepServiceError, statusCode)
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected %v for status %d, got %v", kind, statusCode, err)
+		}
+		if !errors.Is(err, CepServiceError) {
+			t.Errorf("Expected CepServiceError for status %d, got %v", statusCode, err)
+		}
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	err := transportError("weatherapi", WeatherServiceError, context.DeadlineExceeded)
+	if !errors.Is(err, UpstreamTimeoutError) {
+		t.Errorf("Expected UpstreamTimeoutError, got %v", err)
+	}
+
+	err = transportError("weatherapi", WeatherServiceError, errors.New("connection refused"))
+	if !errors.Is(err, UpstreamUnavailableError) {
+		t.Errorf("Expected UpstreamUnavailableError, got %v", err)
+	}
+	if !errors.Is(err, WeatherServiceError) {
+		t.Errorf("Expected WeatherServiceError, got %v", err)
+	}
+}
+
+func TestWeatherApiError(t *testing.T) {
+	expected := map[string]error{
+		`{"error":{"code":1006,"message":"No matching location found."}}`: LocationNotFoundError,
+		`{"error":{"code":2006,"message":"API key is invalid."}}`:         UpstreamAuthError,
+		`{"error":{"code":2007,"message":"API key has exceeded calls."}}`: UpstreamRateLimitedError,
+		`{"error":{"code":9999,"message":"Internal application error."}}`: UpstreamUnavailableError,
+		`<html>bad gateway</html>`:                                        UpstreamUnavailableError,
+	}
+
+	for body, kind := range expected {
+		err := weatherApiError(http.StatusBadRequest, []byte(body))
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected %v for %s, got %v", kind, body, err)
+		}
+	}
+}
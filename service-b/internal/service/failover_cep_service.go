@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var NoCepProviderAvailableError = errors.New("no cep provider available")
+
+type CepProvider struct {
+	Name    string
+	Service CepService
+}
+
+// availability is implemented by services that can tell they should not be
+// called, e.g. because their circuit is open.
+type availability interface {
+	Available() bool
+}
+
+// FailoverCepService asks each provider in order until one of them finds the
+// address. Providers reporting themselves unavailable are skipped. A not
+// found answer is authoritative and stops the lookup: the providers all
+// serve the Correios database, so asking the others for a CEP one of them
+// does not know would only add load and latency to the most common bad
+// input.
+type FailoverCepService struct {
+	providers []CepProvider
+}
+
+func NewFailoverCepService(providers ...CepProvider) *FailoverCepService {
+	return &FailoverCepService{providers: providers}
+}
+
+func (f *FailoverCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - Failover")
+	defer span.End()
+
+	var errs []error
+	for _, provider := range f.providers {
+		if a, ok := provider.Service.(availability); ok && !a.Available() {
+			span.AddEvent("cep provider skipped", trace.WithAttributes(attribute.String("cep.provider", provider.Name)))
+			continue
+		}
+
+		address, err := provider.Service.GetAddressByCep(ctx, cep)
+		if err == nil {
+			if address.Provider == "" {
+				address.Provider = provider.Name
+			}
+			span.SetAttributes(attribute.String("cep.provider", address.Provider))
+			return address, nil
+		}
+
+		if errors.Is(err, CepNotFoundError) {
+			span.SetAttributes(attribute.String("cep.provider", provider.Name))
+			return nil, CepNotFoundError
+		}
+		span.AddEvent("cep provider failed", trace.WithAttributes(
+			attribute.String("cep.provider", provider.Name),
+			attribute.String("error", err.Error()),
+		))
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return nil, NoCepProviderAvailableError
+	}
+
+	err := errors.Join(errs...)
+	recordError(span, err)
+	return nil, err
+}
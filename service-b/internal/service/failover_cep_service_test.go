@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type unavailableCepService struct {
+	fakeCepService
+}
+
+func (u *unavailableCepService) Available() bool {
+	return false
+}
+
+func TestFailoverCepService(t *testing.T) {
+	ctx := context.Background()
+	failing := &fakeCepService{err: &UpstreamError{Upstream: "viacep", Kind: UpstreamUnavailableError}}
+	skipped := &unavailableCepService{}
+	answering := &fakeCepService{address: &ViaCepResponse{Cep: "01001000", Localidade: "São Paulo"}}
+
+	service := NewFailoverCepService(
+		CepProvider{Name: "viacep", Service: failing},
+		CepProvider{Name: "brasilapi", Service: skipped},
+		CepProvider{Name: "opencep", Service: answering},
+	)
+
+	address, err := service.GetAddressByCep(ctx, "01001000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Provider != "opencep" {
+		t.Errorf("Expected opencep, got %s", address.Provider)
+	}
+	if failing.calls != 1 || skipped.calls != 0 || answering.calls != 1 {
+		t.Errorf("Unexpected calls viacep=%d brasilapi=%d opencep=%d", failing.calls, skipped.calls, answering.calls)
+	}
+}
+
+func TestFailoverCepServiceNotFound(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeCepService{err: CepNotFoundError}
+	service := NewFailoverCepService(
+		CepProvider{Name: "viacep", Service: &fakeCepService{err: fmt.Errorf("viacep: %w", CepNotFoundError)}},
+		CepProvider{Name: "opencep", Service: next},
+	)
+	if _, err := service.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+		t.Errorf("Expected CepNotFoundError, got %v", err)
+	}
+	if next.calls != 0 {
+		t.Errorf("Expected a not found answer to stop the lookup, got %d calls", next.calls)
+	}
+
+	// A failing provider still moves on to the next one.
+	service = NewFailoverCepService(
+		CepProvider{Name: "viacep", Service: &fakeCepService{err: &UpstreamError{Upstream: "viacep", Kind: UpstreamTimeoutError}}},
+		CepProvider{Name: "brasilapi", Service: &unavailableCepService{}},
+		CepProvider{Name: "opencep", Service: &fakeCepService{err: CepNotFoundError}},
+	)
+	if _, err := service.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+		t.Errorf("Expected CepNotFoundError, got %v", err)
+	}
+
+	service = NewFailoverCepService(CepProvider{Name: "brasilapi", Service: &unavailableCepService{}})
+	if _, err := service.GetAddressByCep(ctx, "01001000"); err != NoCepProviderAvailableError {
+		t.Errorf("Expected NoCepProviderAvailableError, got %v", err)
+	}
+}
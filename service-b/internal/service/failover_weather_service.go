@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var NoWeatherProviderAvailableError = errors.New("no weather provider available")
+
+type WeatherProvider struct {
+	Name    string
+	Service WeatherService
+}
+
+// FailoverWeatherService asks each provider in order until one of them
+// answers, following the same rules as FailoverCepService.
+type FailoverWeatherService struct {
+	providers []WeatherProvider
+}
+
+func NewFailoverWeatherService(providers ...WeatherProvider) *FailoverWeatherService {
+	return &FailoverWeatherService{providers: providers}
+}
+
+func (f *FailoverWeatherService) GetWeatherByLocation(ctx context.Context, location Location) (*WeatherResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - Failover")
+	defer span.End()
+
+	var errs []error
+	var notFound error
+	for _, provider := range f.providers {
+		if a, ok := provider.Service.(availability); ok && !a.Available() {
+			span.AddEvent("weather provider skipped", trace.WithAttributes(attribute.String("weather.provider", provider.Name)))
+			continue
+		}
+
+		weather, err := provider.Service.GetWeatherByLocation(ctx, location)
+		if err == nil {
+			if weather.Provider == "" {
+				weather.Provider = provider.Name
+			}
+			span.SetAttributes(attribute.String("weather.provider", weather.Provider))
+			return weather, nil
+		}
+
+		span.AddEvent("weather provider failed", trace.WithAttributes(
+			attribute.String("weather.provider", provider.Name),
+			attribute.String("error", err.Error()),
+		))
+		if errors.Is(err, LocationNotFoundError) {
+			notFound = err
+			continue
+		}
+		errs = append(errs, err)
+	}
+
+	if notFound != nil && len(errs) == 0 {
+		return nil, notFound
+	}
+	if len(errs) == 0 {
+		return nil, NoWeatherProviderAvailableError
+	}
+
+	err := errors.Join(errs...)
+	recordError(span, err)
+	return nil, err
+}
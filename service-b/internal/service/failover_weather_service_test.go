@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type unavailableWeatherService struct {
+	fakeWeatherService
+}
+
+func (u *unavailableWeatherService) Available() bool {
+	return false
+}
+
+func TestFailoverWeatherService(t *testing.T) {
+	ctx := context.Background()
+	failing := &fakeWeatherService{err: &UpstreamError{Upstream: "weatherapi", Kind: UpstreamAuthError}}
+	skipped := &unavailableWeatherService{}
+	answering := &fakeWeatherService{weather: &WeatherResponse{Name: "São Paulo", Temp_c: 20}}
+
+	service := NewFailoverWeatherService(
+		WeatherProvider{Name: "weatherapi", Service: failing},
+		WeatherProvider{Name: "openweathermap", Service: skipped},
+		WeatherProvider{Name: "openmeteo", Service: answering},
+	)
+
+	weather, err := service.GetWeatherByLocation(ctx, Location{City: "São Paulo", Uf: "SP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Provider != "openmeteo" {
+		t.Errorf("Expected openmeteo, got %s", weather.Provider)
+	}
+	if failing.calls != 1 || skipped.calls != 0 || answering.calls != 1 {
+		t.Errorf("Unexpected calls weatherapi=%d openweathermap=%d openmeteo=%d", failing.calls, skipped.calls, answering.calls)
+	}
+}
+
+func TestFailoverWeatherServiceErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewFailoverWeatherService(
+		WeatherProvider{Name: "weatherapi", Service: &fakeWeatherService{err: LocationNotFoundError}},
+		WeatherProvider{Name: "openmeteo", Service: &fakeWeatherService{err: LocationNotFoundError}},
+	)
+	if _, err := service.GetWeatherByLocation(ctx, Location{City: "Atlantis"}); err != LocationNotFoundError {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+
+	service = NewFailoverWeatherService(
+		WeatherProvider{Name: "weatherapi", Service: &fakeWeatherService{err: LocationNotFoundError}},
+		WeatherProvider{Name: "openmeteo", Service: &fakeWeatherService{err: &UpstreamError{Upstream: "openmeteo", Kind: UpstreamTimeoutError}}},
+	)
+	if _, err := service.GetWeatherByLocation(ctx, Location{City: "São Paulo", Uf: "SP"}); !errors.Is(err, UpstreamTimeoutError) {
+		t.Errorf("Expected UpstreamTimeoutError, got %v", err)
+	}
+
+	service = NewFailoverWeatherService(WeatherProvider{Name: "openmeteo", Service: &unavailableWeatherService{}})
+	if _, err := service.GetWeatherByLocation(ctx, Location{City: "São Paulo", Uf: "SP"}); err != NoWeatherProviderAvailableError {
+		t.Errorf("Expected NoWeatherProviderAvailableError, got %v", err)
+	}
+}
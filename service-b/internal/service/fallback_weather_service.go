@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type FallbackOptions struct {
+	// MaxStale is how old a last known reading may be to still be served.
+	MaxStale time.Duration
+	// RefreshTimeout bounds the background refresh started after a failure.
+	RefreshTimeout time.Duration
+	Size           int
+}
+
+// FallbackWeatherService remembers the last reading of each location and serves
+// it, flagged as stale, when the wrapped WeatherService fails. A background
+// refresh is started meanwhile; until it finishes further requests for the
+// location are answered with the stale reading instead of waiting on the
+// failing provider.
+type FallbackWeatherService struct {
+	next      WeatherService
+	opts      FallbackOptions
+	lastKnown *cache.LRU[string, WeatherResponse]
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+func NewFallbackWeatherService(next WeatherService, opts FallbackOptions) *FallbackWeatherService {
+	return &FallbackWeatherService{
+		next:       next,
+		opts:       opts,
+		lastKnown:  cache.NewLRU[string, WeatherResponse](opts.Size),
+		refreshing: map[string]bool{},
+	}
+}
+
+func (f *FallbackWeatherService) GetWeatherByLocation(ctx context.Context, location Location) (*WeatherResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - Fallback")
+	defer span.End()
+
+	key := location.Key()
+	if f.isRefreshing(key) {
+		if weather, ok := f.stale(span, key); ok {
+			return weather, nil
+		}
+	}
+
+	weather, err := f.next.GetWeatherByLocation(ctx, location)
+	if err == nil {
+		f.lastKnown.Set(key, *weather, f.opts.MaxStale)
+		span.SetAttributes(attribute.Bool("weather.stale", false))
+		return weather, nil
+	}
+
+	if errors.Is(err, LocationNotFoundError) {
+		return nil, err
+	}
+
+	stale, ok := f.stale(span, key)
+	if !ok {
+		return nil, err
+	}
+
+	span.RecordError(err)
+	f.refresh(ctx, key, location)
+	return stale, nil
+}
+
+func (f *FallbackWeatherService) stale(span trace.Span, key string) (*WeatherResponse, bool) {
+	weather, ok := f.lastKnown.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	weather.Stale = true
+	span.SetAttributes(
+		attribute.Bool("weather.stale", true),
+		attribute.String("weather.observed_at", weather.ObservedAt.Format(time.RFC3339)),
+	)
+	return &weather, true
+}
+
+func (f *FallbackWeatherService) isRefreshing(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.refreshing[key]
+}
+
+// refresh fetches location in the background, at most once at a time per
+// location.
+func (f *FallbackWeatherService) refresh(ctx context.Context, key string, location Location) {
+	f.mu.Lock()
+	if f.refreshing[key] {
+		f.mu.Unlock()
+		return
+	}
+	f.refreshing[key] = true
+	f.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), f.opts.RefreshTimeout)
+	go func() {
+		defer cancel()
+		defer func() {
+			f.mu.Lock()
+			delete(f.refreshing, key)
+			f.mu.Unlock()
+		}()
+
+		tracer := otel.Tracer("a-b-trace")
+		ctx, span := tracer.Start(ctx, "GetWeatherByLocation - Refresh")
+		defer span.End()
+
+		weather, err := f.next.GetWeatherByLocation(ctx, location)
+		if err != nil {
+			log.Println("Error refreshing weather for ", location, ": ", err)
+			return
+		}
+		f.lastKnown.Set(key, *weather, f.opts.MaxStale)
+	}()
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFallbackWeatherService(t *testing.T) {
+	ctx := context.Background()
+	curitiba := Location{City: "Curitiba", Uf: "PR", Ibge: "4106902"}
+	observedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	next := &fakeWeatherService{weather: &WeatherResponse{Name: "Curitiba", Temp_c: 12, ObservedAt: observedAt}}
+	service := NewFallbackWeatherService(next, FallbackOptions{MaxStale: time.Hour, RefreshTimeout: time.Second, Size: 10})
+
+	weather, err := service.GetWeatherByLocation(ctx, curitiba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Stale {
+		t.Error("Expected fresh reading")
+	}
+
+	next.set(nil, &UpstreamError{Upstream: "weatherapi", Kind: UpstreamTimeoutError})
+	weather, err = service.GetWeatherByLocation(ctx, curitiba)
+	if err != nil {
+		t.Fatal("Expected last known reading, got", err)
+	}
+	if !weather.Stale {
+		t.Error("Expected stale reading")
+	}
+	if weather.Temp_c != 12 || !weather.ObservedAt.Equal(observedAt) {
+		t.Errorf("Expected last known reading, got %+v", weather)
+	}
+
+	next.set(&WeatherResponse{Name: "Curitiba", Temp_c: 15, ObservedAt: observedAt.Add(time.Hour)}, nil)
+	deadline := time.Now().Add(time.Second)
+	for service.isRefreshing(curitiba.Key()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	weather, err = service.GetWeatherByLocation(ctx, curitiba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weather.Stale || weather.Temp_c != 15 {
+		t.Errorf("Expected refreshed reading, got %+v", weather)
+	}
+}
+
+func TestFallbackWeatherServiceWithoutReading(t *testing.T) {
+	next := &fakeWeatherService{err: &UpstreamError{Upstream: "weatherapi", Kind: UpstreamUnavailableError}}
+	service := NewFallbackWeatherService(next, FallbackOptions{MaxStale: time.Hour, RefreshTimeout: time.Second, Size: 10})
+
+	if _, err := service.GetWeatherByLocation(context.Background(), Location{City: "Curitiba", Uf: "PR", Ibge: "4106902"}); err == nil {
+		t.Error("Expected provider error without a last known reading")
+	}
+}
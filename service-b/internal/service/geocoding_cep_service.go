@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/geocoding"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GeocodingCepService sets the coordinates of the addresses returned by the
+// wrapped CepService from the IBGE code of their municipality. Coordinates
+// already returned by the provider are kept.
+type GeocodingCepService struct {
+	next     CepService
+	geocoder *geocoding.Geocoder
+}
+
+func NewGeocodingCepService(next CepService, geocoder *geocoding.Geocoder) *GeocodingCepService {
+	return &GeocodingCepService{next: next, geocoder: geocoder}
+}
+
+func (g *GeocodingCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+	address, err := g.next.GetAddressByCep(ctx, cep)
+	if err != nil || address.Coordinates != nil {
+		return address, err
+	}
+
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - Geocoding")
+	defer span.End()
+
+	// Municipalities missing from the table are left to the city search of
+	// the weather providers.
+	municipality, ok := g.geocoder.Lookup(address.Ibge)
+	span.SetAttributes(attribute.Bool("geocoding.found", ok), attribute.String("geocoding.ibge", address.Ibge))
+	telemetry.RecordGeocodingLookup(ctx, ok)
+	if !ok {
+		return address, nil
+	}
+
+	geocoded := *address
+	geocoded.Coordinates = &Coordinates{Latitude: municipality.Latitude, Longitude: municipality.Longitude}
+	return &geocoded, nil
+}
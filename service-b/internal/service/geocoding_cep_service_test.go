@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/geocoding"
+)
+
+func TestGeocodingCepService(t *testing.T) {
+	ctx := context.Background()
+	next := &fakeCepService{address: &ViaCepResponse{Cep: "97010-000", Localidade: "Santa Maria", Uf: "RS", Ibge: "4316907"}}
+	service := NewGeocodingCepService(next, geocoding.NewEmbeddedGeocoder())
+
+	address, err := service.GetAddressByCep(ctx, "97010000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Coordinates == nil || address.Coordinates.Latitude != -29.6868 || address.Coordinates.Longitude != -53.8149 {
+		t.Errorf("Expected Santa Maria coordinates, got %+v", address.Coordinates)
+	}
+	if next.address.Coordinates != nil {
+		t.Error("Expected the wrapped address not to be modified")
+	}
+
+	next.address = &ViaCepResponse{Localidade: "Unknown", Ibge: "0000000"}
+	if address, _ := service.GetAddressByCep(ctx, "00000000"); address.Coordinates != nil {
+		t.Errorf("Expected no coordinates for an unknown IBGE code, got %+v", address.Coordinates)
+	}
+
+	provided := &Coordinates{Latitude: -29.69, Longitude: -53.8}
+	next.address = &ViaCepResponse{Localidade: "Santa Maria", Ibge: "4316907", Coordinates: provided}
+	if address, _ := service.GetAddressByCep(ctx, "97010000"); address.Coordinates != provided {
+		t.Errorf("Expected provider coordinates to be kept, got %+v", address.Coordinates)
+	}
+}
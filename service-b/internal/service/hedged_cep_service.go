@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hedgeMinSamples is the number of latencies observed before the hedge
+// delay follows their percentile instead of MaxDelay.
+const hedgeMinSamples = 20
+
+type HedgeOptions struct {
+	// Percentile of the recent answer latencies after which a hedge request
+	// is fired, e.g. 0.95.
+	Percentile float64
+	// Window is the number of recent latencies the percentile is taken
+	// over.
+	Window int
+	// MinDelay and MaxDelay bound the hedge delay. MaxDelay is also used
+	// until enough latencies were observed.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// HedgedCepService asks the first available provider and, when it has not
+// answered within the hedge delay, fires a hedge request to the next one,
+// or to the same one when it is the only provider. The first address or
+// not found answer wins and the other request is cancelled. Failed requests
+// move on to the next provider, as FailoverCepService does.
+type HedgedCepService struct {
+	providers []CepProvider
+	opts      HedgeOptions
+	latencies *latencyWindow
+}
+
+func NewHedgedCepService(opts HedgeOptions, providers ...CepProvider) *HedgedCepService {
+	if opts.Window <= 0 {
+		opts.Window = hedgeMinSamples
+	}
+	if opts.Percentile <= 0 || opts.Percentile > 1 {
+		opts.Percentile = 1
+	}
+
+	return &HedgedCepService{
+		providers: providers,
+		opts:      opts,
+		latencies: newLatencyWindow(opts.Window),
+	}
+}
+
+type hedgeResult struct {
+	provider CepProvider
+	// primary is set for the first request of the lookup, whose latency
+	// the hedge delay predicts.
+	primary bool
+	hedge   bool
+	address *ViaCepResponse
+	err     error
+	latency time.Duration
+}
+
+func (h *HedgedCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - Hedged")
+	defer span.End()
+
+	var available []CepProvider
+	for _, provider := range h.providers {
+		if a, ok := provider.Service.(availability); ok && !a.Available() {
+			span.AddEvent("cep provider skipped", trace.WithAttributes(attribute.String("cep.provider", provider.Name)))
+			continue
+		}
+		available = append(available, provider)
+	}
+	if len(available) == 0 {
+		return nil, NoCepProviderAvailableError
+	}
+
+	// Cancels the request still in flight once one answered.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, len(available)+1)
+	inflight := 0
+	launch := func(provider CepProvider, hedge bool) {
+		primary := inflight == 0 && !hedge
+		inflight++
+		go func() {
+			start := time.Now()
+			address, err := provider.Service.GetAddressByCep(ctx, cep)
+			results <- hedgeResult{provider: provider, primary: primary, hedge: hedge, address: address, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	start := time.Now()
+	launch(available[0], false)
+	next := 1
+	primaryDone := false
+
+	delay := h.delay()
+	span.SetAttributes(attribute.Int64("hedge.delay_ms", delay.Milliseconds()))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var (
+		errs   []error
+		hedged *CepProvider
+	)
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			var target CepProvider
+			switch {
+			case next < len(available):
+				target = available[next]
+				next++
+			case len(available) == 1:
+				target = available[0]
+			default:
+				continue
+			}
+			span.AddEvent("cep hedge fired", trace.WithAttributes(attribute.String("cep.provider", target.Name)))
+			hedged = &target
+			launch(target, true)
+		case result := <-results:
+			inflight--
+			if result.primary {
+				primaryDone = true
+			}
+			if result.err == nil || errors.Is(result.err, CepNotFoundError) {
+				// Only the latencies of primary requests are observed. One
+				// beaten by a hedge is observed for as long as it ran, a
+				// lower bound of its latency: observing the hedge instead
+				// would drag the delay down and hedge every lookup.
+				switch {
+				case result.primary:
+					h.latencies.observe(result.latency)
+				case !primaryDone:
+					h.latencies.observe(time.Since(start))
+				}
+				if hedged != nil {
+					telemetry.RecordHedgedRequest(ctx, "cep", hedged.Name, result.hedge)
+				}
+				if result.err != nil {
+					span.SetAttributes(
+						attribute.String("cep.provider", result.provider.Name),
+						attribute.Bool("hedge.won", result.hedge),
+					)
+					return nil, CepNotFoundError
+				}
+				if result.address.Provider == "" {
+					result.address.Provider = result.provider.Name
+				}
+				span.SetAttributes(
+					attribute.String("cep.provider", result.address.Provider),
+					attribute.Bool("hedge.won", result.hedge),
+				)
+				return result.address, nil
+			}
+
+			span.AddEvent("cep provider failed", trace.WithAttributes(
+				attribute.String("cep.provider", result.provider.Name),
+				attribute.String("error", result.err.Error()),
+			))
+			errs = append(errs, result.err)
+			if next < len(available) {
+				launch(available[next], false)
+				next++
+			}
+		}
+	}
+
+	if hedged != nil {
+		telemetry.RecordHedgedRequest(ctx, "cep", hedged.Name, false)
+	}
+	err := errors.Join(errs...)
+	recordError(span, err)
+	return nil, err
+}
+
+// delay returns the hedge delay, the configured percentile of the recent
+// latencies within MinDelay and MaxDelay.
+func (h *HedgedCepService) delay() time.Duration {
+	latency, ok := h.latencies.percentile(h.opts.Percentile)
+	if !ok {
+		return h.opts.MaxDelay
+	}
+	latency = max(latency, h.opts.MinDelay)
+	if h.opts.MaxDelay > 0 {
+		latency = min(latency, h.opts.MaxDelay)
+	}
+	return latency
+}
+
+// latencyWindow keeps the last latencies observed.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, 0, size)}
+}
+
+func (l *latencyWindow) observe(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < cap(l.samples) {
+		l.samples = append(l.samples, latency)
+		return
+	}
+	l.samples[l.next] = latency
+	l.next = (l.next + 1) % len(l.samples)
+}
+
+// percentile returns the p percentile of the window, once it holds enough
+// samples to be meaningful.
+func (l *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+
+	if len(sorted) < min(hedgeMinSamples, cap(l.samples)) {
+		return 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(index, 0), len(sorted)-1)], true
+}
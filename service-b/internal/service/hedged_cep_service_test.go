@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowCepService answers after the delay of each call in turn, the last one
+// repeating, and records the calls cancelled before answering.
+type slowCepService struct {
+	delays  []time.Duration
+	address *ViaCepResponse
+	err     error
+
+	mu        sync.Mutex
+	calls     int
+	cancelled int
+}
+
+func (s *slowCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+	s.mu.Lock()
+	delay := s.delays[min(s.calls, len(s.delays)-1)]
+	s.calls++
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+		if s.err != nil {
+			return nil, s.err
+		}
+		address := *s.address
+		return &address, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.cancelled++
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (s *slowCepService) counts() (calls, cancelled int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls, s.cancelled
+}
+
+func TestHedgedCepService(t *testing.T) {
+	slow := &slowCepService{delays: []time.Duration{time.Second}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+	fast := &slowCepService{delays: []time.Duration{0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+
+	service := NewHedgedCepService(HedgeOptions{Percentile: 0.95, MaxDelay: 10 * time.Millisecond},
+		CepProvider{Name: "viacep", Service: slow},
+		CepProvider{Name: "brasilapi", Service: fast},
+	)
+
+	start := time.Now()
+	address, err := service.GetAddressByCep(context.Background(), "01001000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Provider != "brasilapi" {
+		t.Errorf("Expected the hedge to win, got %s", address.Provider)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the slow provider not to be waited on, took %s", elapsed)
+	}
+
+	// The loser is cancelled once the winner answered.
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, cancelled := slow.counts(); cancelled == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the slow request to be cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHedgedCepServiceFastProvider(t *testing.T) {
+	fast := &slowCepService{delays: []time.Duration{0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+	other := &slowCepService{delays: []time.Duration{0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+
+	service := NewHedgedCepService(HedgeOptions{Percentile: 0.95, MaxDelay: time.Second},
+		CepProvider{Name: "viacep", Service: fast},
+		CepProvider{Name: "brasilapi", Service: other},
+	)
+
+	address, err := service.GetAddressByCep(context.Background(), "01001000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls, _ := other.counts(); address.Provider != "viacep" || calls != 0 {
+		t.Errorf("Expected no hedge, got %s and %d hedge calls", address.Provider, calls)
+	}
+}
+
+func TestHedgedCepServiceSingleProvider(t *testing.T) {
+	provider := &slowCepService{delays: []time.Duration{time.Second, 0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+
+	service := NewHedgedCepService(HedgeOptions{Percentile: 0.95, MaxDelay: 10 * time.Millisecond},
+		CepProvider{Name: "viacep", Service: provider},
+	)
+
+	if _, err := service.GetAddressByCep(context.Background(), "01001000"); err != nil {
+		t.Fatal(err)
+	}
+	if calls, _ := provider.counts(); calls != 2 {
+		t.Errorf("Expected the hedge sent to the same provider, got %d calls", calls)
+	}
+}
+
+func TestHedgedCepServiceFailures(t *testing.T) {
+	ctx := context.Background()
+	options := HedgeOptions{Percentile: 0.95, MaxDelay: time.Second}
+
+	failing := &slowCepService{delays: []time.Duration{0}, err: &UpstreamError{Upstream: "viacep", Kind: UpstreamUnavailableError}}
+	answering := &slowCepService{delays: []time.Duration{0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+	service := NewHedgedCepService(options,
+		CepProvider{Name: "viacep", Service: failing},
+		CepProvider{Name: "brasilapi", Service: &unavailableCepService{}},
+		CepProvider{Name: "opencep", Service: answering},
+	)
+	address, err := service.GetAddressByCep(ctx, "01001000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Provider != "opencep" {
+		t.Errorf("Expected a failure to move on to opencep, got %s", address.Provider)
+	}
+
+	notFound := &slowCepService{delays: []time.Duration{0}, err: CepNotFoundError}
+	next := &slowCepService{delays: []time.Duration{0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+	service = NewHedgedCepService(options,
+		CepProvider{Name: "viacep", Service: notFound},
+		CepProvider{Name: "opencep", Service: next},
+	)
+	if _, err := service.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+		t.Errorf("Expected CepNotFoundError, got %v", err)
+	}
+	if calls, _ := next.counts(); calls != 0 {
+		t.Errorf("Expected a not found answer to stop the lookup, got %d calls", calls)
+	}
+
+	service = NewHedgedCepService(options,
+		CepProvider{Name: "viacep", Service: failing},
+		CepProvider{Name: "opencep", Service: notFound},
+	)
+	if _, err := service.GetAddressByCep(ctx, "00000000"); err != CepNotFoundError {
+		t.Errorf("Expected a failure to move on to a not found answer, got %v", err)
+	}
+}
+
+func TestHedgedCepServiceDelay(t *testing.T) {
+	service := NewHedgedCepService(HedgeOptions{Percentile: 0.9, Window: 100, MinDelay: 5 * time.Millisecond, MaxDelay: time.Second})
+
+	if delay := service.delay(); delay != time.Second {
+		t.Errorf("Expected MaxDelay before enough latencies were observed, got %s", delay)
+	}
+
+	for i := 1; i <= 100; i++ {
+		service.latencies.observe(time.Duration(i) * time.Millisecond)
+	}
+	if delay := service.delay(); delay != 90*time.Millisecond {
+		t.Errorf("Expected the 90th percentile, got %s", delay)
+	}
+
+	for i := 0; i < 100; i++ {
+		service.latencies.observe(time.Millisecond)
+	}
+	if delay := service.delay(); delay != 5*time.Millisecond {
+		t.Errorf("Expected MinDelay, got %s", delay)
+	}
+}
+
+func TestHedgedCepServiceSlowPrimaryKeepsDelay(t *testing.T) {
+	slow := &slowCepService{delays: []time.Duration{time.Second}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+	fast := &slowCepService{delays: []time.Duration{0}, address: &ViaCepResponse{Localidade: "São Paulo"}}
+
+	service := NewHedgedCepService(HedgeOptions{Percentile: 0.5, Window: 20, MinDelay: time.Millisecond, MaxDelay: time.Second},
+		CepProvider{Name: "viacep", Service: slow},
+		CepProvider{Name: "brasilapi", Service: fast},
+	)
+	for i := 0; i < 20; i++ {
+		service.latencies.observe(20 * time.Millisecond)
+	}
+
+	// Every lookup is won by the hedge, which must not pull the delay
+	// under the time the primary was seen to take.
+	for i := 0; i < 40; i++ {
+		if _, err := service.GetAddressByCep(context.Background(), "01001000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if delay := service.delay(); delay < 20*time.Millisecond {
+		t.Errorf("Expected the delay to stay above 20ms, got %s", delay)
+	}
+}
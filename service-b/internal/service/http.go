@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultUpstreamTimeout bounds the requests of the providers built without
+// a client, retries included. It stays under the default timeout of
+// service-a, so its requests are answered before they time out.
+const DefaultUpstreamTimeout = 4 * time.Second
+
+// upstreamClient returns client, or one bounded by DefaultUpstreamTimeout
+// when nil.
+func upstreamClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: DefaultUpstreamTimeout}
+	}
+	return client
+}
+
+// getJSON requests url and decodes a 200 answer into out. 404 answers are
+// reported through notFound, every other failure is classified as an
+// UpstreamError of upstream.
+func getJSON(ctx context.Context, client *http.Client, upstream string, provider error, url string, out any) (notFound bool, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return false, transportError(upstream, provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return true, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, statusError(upstream, provider, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, payloadError(upstream, provider, err)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return false, payloadError(upstream, provider, err)
+	}
+
+	return false, nil
+}
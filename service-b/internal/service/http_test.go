@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProvidersTimeOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	client := &http.Client{Timeout: 20 * time.Millisecond}
+	cepProviders := map[string]CepService{
+		"viacep":     NewViaCepService(server.URL, client),
+		"brasilapi":  NewBrasilApiService(server.URL, client),
+		"opencep":    NewOpenCepService(server.URL, client),
+		"awesomeapi": NewAwesomeApiService(server.URL, client),
+	}
+	for name, provider := range cepProviders {
+		if _, err := provider.GetAddressByCep(context.Background(), "01001000"); !errors.Is(err, UpstreamTimeoutError) {
+			t.Errorf("%s: expected UpstreamTimeoutError, got %v", name, err)
+		}
+	}
+
+	weatherProviders := map[string]WeatherService{
+		"weatherapi":     NewWeatherApiService("key", server.URL, client),
+		"openmeteo":      NewOpenMeteoService(server.URL, server.URL, client),
+		"openweathermap": NewOpenWeatherMapService("key", server.URL, client),
+	}
+	for name, provider := range weatherProviders {
+		if _, err := provider.GetWeatherByLocation(context.Background(), saoPaulo); !errors.Is(err, UpstreamTimeoutError) {
+			t.Errorf("%s: expected UpstreamTimeoutError, got %v", name, err)
+		}
+	}
+
+	if client := upstreamClient(nil); client.Timeout != DefaultUpstreamTimeout {
+		t.Errorf("Expected providers built without a client to time out after %s, got %s", DefaultUpstreamTimeout, client.Timeout)
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CountryBrazil is the ISO 3166-1 code of the only country served.
+const CountryBrazil = "BR"
+
+// Location identifies the city a weather reading is requested for. The UF
+// and the IBGE code disambiguate cities sharing a name, like Santa Maria
+// (RS and DF) or Bom Jesus (PI, RS, SC...).
+type Location struct {
+	City    string
+	Uf      string
+	Ibge    string
+	Country string
+	// Coordinates are set when the location was geocoded, letting
+	// providers skip their own search.
+	Coordinates *Coordinates
+}
+
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (c Coordinates) String() string {
+	return strconv.FormatFloat(c.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(c.Longitude, 'f', -1, 64)
+}
+
+// NewLocation builds the location of the address found for a CEP.
+func NewLocation(address *ViaCepResponse) Location {
+	return Location{
+		City:        address.Localidade,
+		Uf:          strings.ToUpper(address.Uf),
+		Ibge:        address.Ibge,
+		Country:     CountryBrazil,
+		Coordinates: address.Coordinates,
+	}
+}
+
+// State returns the name of the location state, or its UF when unknown.
+func (l Location) State() string {
+	if name, ok := stateNames[l.Uf]; ok {
+		return name
+	}
+	return l.Uf
+}
+
+// Key identifies the location in caches: the IBGE code when known, the
+// normalized city and UF otherwise.
+func (l Location) Key() string {
+	if l.Ibge != "" {
+		return l.Ibge
+	}
+	return foldName(l.City) + "/" + strings.ToLower(l.Uf)
+}
+
+func (l Location) String() string {
+	if l.Uf == "" {
+		return l.City
+	}
+	return l.City + " - " + l.Uf
+}
+
+// locationCandidate is a place returned by the search endpoint of a
+// weather provider.
+type locationCandidate struct {
+	Name    string
+	Region  string
+	Country string
+}
+
+// resolveLocation returns the index of the candidate matching l. Places
+// outside Brazil are never picked; when the UF is known only candidates in
+// its state, or named exactly like the city without a state at all, are
+// accepted. An exact name outweighs the state, so a candidate named like the
+// city is preferred to another city of the same state.
+func resolveLocation(l Location, candidates []locationCandidate) (int, error) {
+	if l.Country != "" && l.Country != CountryBrazil {
+		return -1, fmt.Errorf("%w: %s is outside Brazil", LocationNotFoundError, l)
+	}
+
+	best, bestScore := -1, 0
+	for i, candidate := range candidates {
+		if !isBrazil(candidate.Country) {
+			continue
+		}
+
+		sameName := foldName(candidate.Name) == foldName(l.City)
+		score := 1
+		if sameName {
+			score += 4
+		}
+		switch {
+		case l.Uf == "":
+		case candidate.Region == "":
+			if !sameName {
+				continue
+			}
+		case sameState(candidate.Region, l):
+			score += 2
+		default:
+			continue
+		}
+
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return -1, fmt.Errorf("%w: %s", LocationNotFoundError, l)
+	}
+	return best, nil
+}
+
+func isBrazil(country string) bool {
+	switch foldName(country) {
+	case "br", "brazil", "brasil":
+		return true
+	}
+	return false
+}
+
+func sameState(region string, l Location) bool {
+	region = foldName(region)
+	return region == foldName(l.State()) || region == strings.ToLower(l.Uf)
+}
+
+var accents = runes.Remove(runes.In(unicode.Mn))
+
+// foldName lowercases name and strips its accents, so "São Paulo" and
+// "Sao Paulo" compare equal.
+func foldName(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, accents, norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+	return strings.ToLower(strings.TrimSpace(folded))
+}
+
+var stateNames = map[string]string{
+	"AC": "Acre",
+	"AL": "Alagoas",
+	"AP": "Amapá",
+	"AM": "Amazonas",
+	"BA": "Bahia",
+	"CE": "Ceará",
+	"DF": "Distrito Federal",
+	"ES": "Espírito Santo",
+	"GO": "Goiás",
+	"MA": "Maranhão",
+	"MT": "Mato Grosso",
+	"MS": "Mato Grosso do Sul",
+	"MG": "Minas Gerais",
+	"PA": "Pará",
+	"PB": "Paraíba",
+	"PR": "Paraná",
+	"PE": "Pernambuco",
+	"PI": "Piauí",
+	"RJ": "Rio de Janeiro",
+	"RN": "Rio Grande do Norte",
+	"RS": "Rio Grande do Sul",
+	"RO": "Rondônia",
+	"RR": "Roraima",
+	"SC": "Santa Catarina",
+	"SP": "São Paulo",
+	"SE": "Sergipe",
+	"TO": "Tocantins",
+}
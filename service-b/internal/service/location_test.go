@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveLocation(t *testing.T) {
+	candidates := []locationCandidate{
+		{Name: "Bom Jesus", Region: "Goias", Country: "Brazil"},
+		{Name: "Bom Jesus", Region: "Rio Grande do Sul", Country: "Brazil"},
+		{Name: "Bom Jesus", Region: "Piauí", Country: "Brazil"},
+		{Name: "Bom Jesus", Region: "Santa Catarina", Country: "BR"},
+	}
+
+	tests := []struct {
+		location Location
+		expected int
+	}{
+		{Location{City: "Bom Jesus", Uf: "RS"}, 1},
+		{Location{City: "Bom Jesus", Uf: "PI"}, 2},
+		{NewLocation(&ViaCepResponse{Localidade: "bom jesus", Uf: "sc"}), 3},
+		{Location{City: "Bom Jesus", Uf: "GO"}, 0},
+		{Location{City: "Bom Jesus"}, 0},
+	}
+
+	for _, test := range tests {
+		i, err := resolveLocation(test.location, candidates)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.location, err)
+		}
+		if i != test.expected {
+			t.Errorf("%s: expected candidate %d, got %d", test.location, test.expected, i)
+		}
+	}
+}
+
+func TestResolveLocationWithoutRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   Location
+		candidates []locationCandidate
+		expected   int
+	}{
+		{
+			name:     "exact name without region beats another city of the state",
+			location: Location{City: "Bom Jesus", Uf: "RS"},
+			candidates: []locationCandidate{
+				{Name: "Vacaria", Region: "Rio Grande do Sul", Country: "Brazil"},
+				{Name: "Bom Jesus", Country: "Brazil"},
+			},
+			expected: 1,
+		},
+		{
+			name:     "exact name in the state beats exact name without region",
+			location: Location{City: "Bom Jesus", Uf: "RS"},
+			candidates: []locationCandidate{
+				{Name: "Bom Jesus", Country: "Brazil"},
+				{Name: "Bom Jesus", Region: "Rio Grande do Sul", Country: "Brazil"},
+			},
+			expected: 1,
+		},
+		{
+			name:     "other name without region is dropped when the UF is known",
+			location: Location{City: "Bom Jesus", Uf: "RS"},
+			candidates: []locationCandidate{
+				{Name: "Jesus Maria", Country: "Brazil"},
+				{Name: "Vacaria", Region: "Rio Grande do Sul", Country: "Brazil"},
+			},
+			expected: 1,
+		},
+		{
+			name:     "other name without region is accepted when the UF is unknown",
+			location: Location{City: "Bom Jesus"},
+			candidates: []locationCandidate{
+				{Name: "Jesus Maria", Country: "Brazil"},
+			},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i, err := resolveLocation(test.location, test.candidates)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if i != test.expected {
+				t.Errorf("Expected candidate %d, got %d", test.expected, i)
+			}
+		})
+	}
+
+	_, err := resolveLocation(Location{City: "Bom Jesus", Uf: "RS"}, []locationCandidate{{Name: "Jesus Maria", Country: "Brazil"}})
+	if !errors.Is(err, LocationNotFoundError) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+}
+
+func TestResolveLocationOutsideBrazil(t *testing.T) {
+	candidates := []locationCandidate{
+		{Name: "Santa Maria", Region: "California", Country: "United States of America"},
+		{Name: "Santa Maria", Region: "Rio Grande do Sul", Country: "Brazil"},
+	}
+
+	if i, err := resolveLocation(Location{City: "Santa Maria", Uf: "RS"}, candidates); err != nil || i != 1 {
+		t.Errorf("Expected the Brazilian candidate, got %d %v", i, err)
+	}
+	if _, err := resolveLocation(Location{City: "Santa Maria"}, candidates[:1]); !errors.Is(err, LocationNotFoundError) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+	if _, err := resolveLocation(Location{City: "Santa Maria", Country: "US"}, candidates); !errors.Is(err, LocationNotFoundError) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+}
+
+func TestLocationKey(t *testing.T) {
+	if key := (Location{City: "São Paulo", Uf: "SP", Ibge: "3550308"}).Key(); key != "3550308" {
+		t.Errorf("Expected 3550308, got %s", key)
+	}
+	if key := (Location{City: "São Paulo", Uf: "SP"}).Key(); key != "sao paulo/sp" {
+		t.Errorf("Expected sao paulo/sp, got %s", key)
+	}
+}
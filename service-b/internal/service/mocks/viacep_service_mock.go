@@ -1,5 +1,10 @@
 // Code generated by MockGen. DO NOT EDIT.
 // Source: ./internal/service/viacep_service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/viacep_service.go -destination=./internal/service/mocks/viacep_service_mock.go -package=mocks
+//
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -9,7 +14,7 @@ import (
 	reflect "reflect"
 
 	service "github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
-	gomock "github.com/golang/mock/gomock"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockCepService is a mock of CepService interface.
@@ -45,7 +50,7 @@ func (m *MockCepService) GetAddressByCep(ctx context.Context, cep string) (*serv
 }
 
 // GetAddressByCep indicates an expected call of GetAddressByCep.
-func (mr *MockCepServiceMockRecorder) GetAddressByCep(ctx, cep interface{}) *gomock.Call {
+func (mr *MockCepServiceMockRecorder) GetAddressByCep(ctx, cep any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressByCep", reflect.TypeOf((*MockCepService)(nil).GetAddressByCep), ctx, cep)
 }
@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/weatherapi_forecast_service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/weatherapi_forecast_service.go -destination=./internal/service/mocks/weatherapi_forecast_service_mock.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	service "github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockForecastService is a mock of ForecastService interface.
+type MockForecastService struct {
+	ctrl     *gomock.Controller
+	recorder *MockForecastServiceMockRecorder
+}
+
+// MockForecastServiceMockRecorder is the mock recorder for MockForecastService.
+type MockForecastServiceMockRecorder struct {
+	mock *MockForecastService
+}
+
+// NewMockForecastService creates a new mock instance.
+func NewMockForecastService(ctrl *gomock.Controller) *MockForecastService {
+	mock := &MockForecastService{ctrl: ctrl}
+	mock.recorder = &MockForecastServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockForecastService) EXPECT() *MockForecastServiceMockRecorder {
+	return m.recorder
+}
+
+// GetForecastByLocation mocks base method.
+func (m *MockForecastService) GetForecastByLocation(ctx context.Context, location service.Location, days int) (*service.ForecastResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForecastByLocation", ctx, location, days)
+	ret0, _ := ret[0].(*service.ForecastResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForecastByLocation indicates an expected call of GetForecastByLocation.
+func (mr *MockForecastServiceMockRecorder) GetForecastByLocation(ctx, location, days any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForecastByLocation", reflect.TypeOf((*MockForecastService)(nil).GetForecastByLocation), ctx, location, days)
+}
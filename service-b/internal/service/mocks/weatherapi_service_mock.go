@@ -1,5 +1,10 @@
 // Code generated by MockGen. DO NOT EDIT.
 // Source: ./internal/service/weatherapi_service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./internal/service/weatherapi_service.go -destination=./internal/service/mocks/weatherapi_service_mock.go -package=mocks
+//
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -9,7 +14,7 @@ import (
 	reflect "reflect"
 
 	service "github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
-	gomock "github.com/golang/mock/gomock"
+	gomock "go.uber.org/mock/gomock"
 )
 
 // MockWeatherService is a mock of WeatherService interface.
@@ -35,17 +40,17 @@ func (m *MockWeatherService) EXPECT() *MockWeatherServiceMockRecorder {
 	return m.recorder
 }
 
-// GetWeatherByCity mocks base method.
-func (m *MockWeatherService) GetWeatherByCity(ctx context.Context, city string) (*service.WeatherResponse, error) {
+// GetWeatherByLocation mocks base method.
+func (m *MockWeatherService) GetWeatherByLocation(ctx context.Context, location service.Location) (*service.WeatherResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWeatherByCity", ctx, city)
+	ret := m.ctrl.Call(m, "GetWeatherByLocation", ctx, location)
 	ret0, _ := ret[0].(*service.WeatherResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetWeatherByCity indicates an expected call of GetWeatherByCity.
-func (mr *MockWeatherServiceMockRecorder) GetWeatherByCity(ctx, city interface{}) *gomock.Call {
+// GetWeatherByLocation indicates an expected call of GetWeatherByLocation.
+func (mr *MockWeatherServiceMockRecorder) GetWeatherByLocation(ctx, location any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWeatherByCity", reflect.TypeOf((*MockWeatherService)(nil).GetWeatherByCity), ctx, city)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWeatherByLocation", reflect.TypeOf((*MockWeatherService)(nil).GetWeatherByLocation), ctx, location)
 }
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+)
+
+const DefaultOpenCepBaseURL = "https://opencep.com"
+
+// OpenCepService looks up addresses with OpenCEP, which answers in the
+// ViaCep format.
+type OpenCepService struct {
+	baseURL string
+	client  *http.Client
+	logger  *log.Logger
+}
+
+func NewOpenCepService(baseURL string, client *http.Client) *OpenCepService {
+	if baseURL == "" {
+		baseURL = DefaultOpenCepBaseURL
+	}
+
+	return &OpenCepService{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  upstreamClient(client),
+		logger:  log.New(os.Stdout, "OpenCepService: ", log.LstdFlags),
+	}
+}
+
+func (o *OpenCepService) GetAddressByCep(ctx context.Context, cep string) (_ *ViaCepResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetAddressByCep - OpenCEP")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() { telemetry.RecordUpstreamCall(ctx, "opencep", outcome, start) }()
+
+	cep = strings.ReplaceAll(cep, "-", "")
+	url := o.baseURL + "/v1/" + cep
+
+	o.logger.Println("Requesting data from OpenCEP: ", url)
+	response := &ViaCepResponse{}
+	notFound, err := getJSON(ctx, o.client, "opencep", CepServiceError, url, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if notFound || response.Cep == "" {
+		outcome = telemetry.OutcomeNotFound
+		return nil, CepNotFoundError
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	response.Provider = "opencep"
+	return response, nil
+}
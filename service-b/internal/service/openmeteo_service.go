@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	DefaultOpenMeteoBaseURL          = "https://api.open-meteo.com"
+	DefaultOpenMeteoGeocodingBaseURL = "https://geocoding-api.open-meteo.com"
+)
+
+var OpenMeteoServiceError = errors.New("error getting weather from open-meteo")
+
+type OpenMeteoGeocodingResponse struct {
+	Results []struct {
+		Name        string  `json:"name"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		CountryCode string  `json:"country_code"`
+		Admin1      string  `json:"admin1"`
+	} `json:"results"`
+}
+
+type OpenMeteoForecastResponse struct {
+	Current struct {
+		Time                string  `json:"time"`
+		Temperature2m       float64 `json:"temperature_2m"`
+		RelativeHumidity2m  float64 `json:"relative_humidity_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		Precipitation       float64 `json:"precipitation"`
+		WeatherCode         int     `json:"weather_code"`
+		PressureMsl         float64 `json:"pressure_msl"`
+		WindSpeed10m        float64 `json:"wind_speed_10m"`
+		WindDirection10m    int     `json:"wind_direction_10m"`
+		UVIndex             float64 `json:"uv_index"`
+	} `json:"current"`
+}
+
+// OpenMeteoService reads the current weather from Open-Meteo. It needs no
+// API key; locations are resolved to coordinates with the Open-Meteo
+// geocoding API.
+type OpenMeteoService struct {
+	baseURL          string
+	geocodingBaseURL string
+	client           *http.Client
+	logger           *log.Logger
+}
+
+func NewOpenMeteoService(baseURL, geocodingBaseURL string, client *http.Client) *OpenMeteoService {
+	if baseURL == "" {
+		baseURL = DefaultOpenMeteoBaseURL
+	}
+	if geocodingBaseURL == "" {
+		geocodingBaseURL = DefaultOpenMeteoGeocodingBaseURL
+	}
+
+	return &OpenMeteoService{
+		baseURL:          strings.TrimSuffix(baseURL, "/"),
+		geocodingBaseURL: strings.TrimSuffix(geocodingBaseURL, "/"),
+		client:           upstreamClient(client),
+		logger:           log.New(os.Stdout, "OpenMeteoService: ", log.LstdFlags),
+	}
+}
+
+func (o *OpenMeteoService) GetWeatherByLocation(ctx context.Context, location Location) (_ *WeatherResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - OpenMeteo")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() { telemetry.RecordUpstreamCall(ctx, "openmeteo", outcome, start) }()
+
+	name, coordinates, err := o.resolve(ctx, location)
+	if err != nil {
+		if errors.Is(err, LocationNotFoundError) {
+			outcome = telemetry.OutcomeNotFound
+		}
+		return nil, err
+	}
+
+	forecastParams := url.Values{}
+	forecastParams.Add("latitude", strconv.FormatFloat(coordinates.Latitude, 'f', -1, 64))
+	forecastParams.Add("longitude", strconv.FormatFloat(coordinates.Longitude, 'f', -1, 64))
+	forecastParams.Add("current", "temperature_2m,relative_humidity_2m,apparent_temperature,precipitation,weather_code,pressure_msl,wind_speed_10m,wind_direction_10m,uv_index")
+	forecastParams.Add("timezone", "GMT")
+
+	o.logger.Println("Requesting weather data from open-meteo")
+	forecast := &OpenMeteoForecastResponse{}
+	if _, err := getJSON(ctx, o.client, "openmeteo", OpenMeteoServiceError, o.baseURL+"/v1/forecast?"+forecastParams.Encode(), forecast); err != nil {
+		return nil, err
+	}
+
+	current := forecast.Current
+	observedAt, err := time.Parse("2006-01-02T15:04", current.Time)
+	if err != nil {
+		return nil, payloadError("openmeteo", OpenMeteoServiceError, err)
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	return &WeatherResponse{
+		Name:       name,
+		Temp_c:     current.Temperature2m,
+		Temp_f:     temperature.ToFahrenheit(current.Temperature2m),
+		ObservedAt: observedAt,
+		Provider:   "openmeteo",
+		Conditions: &Conditions{
+			Humidity:    current.RelativeHumidity2m,
+			WindKph:     current.WindSpeed10m,
+			WindDegree:  current.WindDirection10m,
+			WindDir:     conditions.WindDirection(current.WindDirection10m),
+			PressureHpa: current.PressureMsl,
+			PrecipMm:    current.Precipitation,
+			UV:          &current.UVIndex,
+			Condition:   Condition{Text: wmoConditions[current.WeatherCode], Code: current.WeatherCode},
+			FeelsLike_c: current.ApparentTemperature,
+			FeelsLike_f: temperature.ToFahrenheit(current.ApparentTemperature),
+		},
+	}, nil
+}
+
+// resolve returns the name and coordinates of the location, searching it
+// with the geocoding API when it was not geocoded.
+func (o *OpenMeteoService) resolve(ctx context.Context, location Location) (string, Coordinates, error) {
+	if location.Coordinates != nil {
+		return location.City, *location.Coordinates, nil
+	}
+
+	geocodingParams := url.Values{}
+	geocodingParams.Add("name", location.City)
+	geocodingParams.Add("count", "10")
+	geocodingParams.Add("language", "pt")
+	geocodingParams.Add("countryCode", CountryBrazil)
+
+	o.logger.Println("Requesting coordinates from open-meteo")
+	geocoding := &OpenMeteoGeocodingResponse{}
+	notFound, err := getJSON(ctx, o.client, "openmeteo", OpenMeteoServiceError, o.geocodingBaseURL+"/v1/search?"+geocodingParams.Encode(), geocoding)
+	if err != nil {
+		return "", Coordinates{}, err
+	}
+	if notFound {
+		return "", Coordinates{}, fmt.Errorf("%w: %s", LocationNotFoundError, location)
+	}
+
+	candidates := make([]locationCandidate, len(geocoding.Results))
+	for i, result := range geocoding.Results {
+		candidates[i] = locationCandidate{Name: result.Name, Region: result.Admin1, Country: result.CountryCode}
+	}
+	i, err := resolveLocation(location, candidates)
+	if err != nil {
+		return "", Coordinates{}, err
+	}
+
+	place := geocoding.Results[i]
+	return place.Name, Coordinates{Latitude: place.Latitude, Longitude: place.Longitude}, nil
+}
+
+// wmoConditions describes the WMO weather interpretation codes returned by
+// Open-Meteo in weather_code.
+var wmoConditions = map[int]string{
+	0:  "Clear sky",
+	1:  "Mainly clear",
+	2:  "Partly cloudy",
+	3:  "Overcast",
+	45: "Fog",
+	48: "Depositing rime fog",
+	51: "Light drizzle",
+	53: "Moderate drizzle",
+	55: "Dense drizzle",
+	56: "Light freezing drizzle",
+	57: "Dense freezing drizzle",
+	61: "Slight rain",
+	63: "Moderate rain",
+	65: "Heavy rain",
+	66: "Light freezing rain",
+	67: "Heavy freezing rain",
+	71: "Slight snow fall",
+	73: "Moderate snow fall",
+	75: "Heavy snow fall",
+	77: "Snow grains",
+	80: "Slight rain showers",
+	81: "Moderate rain showers",
+	82: "Violent rain showers",
+	85: "Slight snow showers",
+	86: "Heavy snow showers",
+	95: "Thunderstorm",
+	96: "Thunderstorm with slight hail",
+	99: "Thunderstorm with heavy hail",
+}
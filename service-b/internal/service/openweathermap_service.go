@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/conditions"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
+	"go.opentelemetry.io/otel"
+)
+
+const DefaultOpenWeatherMapBaseURL = "https://api.openweathermap.org"
+
+var OpenWeatherMapServiceError = errors.New("error getting weather from openweathermap")
+
+type OpenWeatherMapGeocodingResponse []struct {
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+}
+
+type OpenWeatherMapResponse struct {
+	Name string `json:"name"`
+	Dt   int64  `json:"dt"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		Pressure  float64 `json:"pressure"`
+		Humidity  float64 `json:"humidity"`
+	} `json:"main"`
+	Wind struct {
+		// Speed is in m/s with metric units.
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+	} `json:"wind"`
+	Rain struct {
+		OneHour float64 `json:"1h"`
+	} `json:"rain"`
+	Weather []struct {
+		Id          int    `json:"id"`
+		Description string `json:"description"`
+	} `json:"weather"`
+	Sys struct {
+		Country string `json:"country"`
+	} `json:"sys"`
+}
+
+// OpenWeatherMapService reads the current weather from the OpenWeatherMap
+// current weather API in metric units, after resolving the location with
+// its geocoding API.
+type OpenWeatherMapService struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+	logger  *log.Logger
+}
+
+func NewOpenWeatherMapService(apiKey, baseURL string, client *http.Client) *OpenWeatherMapService {
+	if baseURL == "" {
+		baseURL = DefaultOpenWeatherMapBaseURL
+	}
+
+	return &OpenWeatherMapService{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  upstreamClient(client),
+		logger:  log.New(os.Stdout, "OpenWeatherMapService: ", log.LstdFlags),
+	}
+}
+
+func (o *OpenWeatherMapService) GetWeatherByLocation(ctx context.Context, location Location) (_ *WeatherResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - OpenWeatherMap")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() { telemetry.RecordUpstreamCall(ctx, "openweathermap", outcome, start) }()
+
+	coordinates, err := o.resolve(ctx, location)
+	if err != nil {
+		if errors.Is(err, LocationNotFoundError) {
+			outcome = telemetry.OutcomeNotFound
+		}
+		return nil, err
+	}
+
+	queryParams := url.Values{}
+	queryParams.Add("lat", strconv.FormatFloat(coordinates.Latitude, 'f', -1, 64))
+	queryParams.Add("lon", strconv.FormatFloat(coordinates.Longitude, 'f', -1, 64))
+	queryParams.Add("units", "metric")
+	queryParams.Add("appid", o.apiKey)
+
+	o.logger.Println("Requesting weather data from openweathermap")
+	response := &OpenWeatherMapResponse{}
+	notFound, err := getJSON(ctx, o.client, "openweathermap", OpenWeatherMapServiceError, o.baseURL+"/data/2.5/weather?"+queryParams.Encode(), response)
+	if err != nil {
+		return nil, err
+	}
+	if notFound || !isBrazil(response.Sys.Country) {
+		outcome = telemetry.OutcomeNotFound
+		return nil, fmt.Errorf("%w: %s", LocationNotFoundError, location)
+	}
+
+	var condition Condition
+	if len(response.Weather) > 0 {
+		condition = Condition{Text: response.Weather[0].Description, Code: response.Weather[0].Id}
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	return &WeatherResponse{
+		Name:       response.Name,
+		Temp_c:     response.Main.Temp,
+		Temp_f:     temperature.ToFahrenheit(response.Main.Temp),
+		ObservedAt: time.Unix(response.Dt, 0).UTC(),
+		Provider:   "openweathermap",
+		Conditions: &Conditions{
+			Humidity:    response.Main.Humidity,
+			WindKph:     response.Wind.Speed * 3.6,
+			WindDegree:  response.Wind.Deg,
+			WindDir:     conditions.WindDirection(response.Wind.Deg),
+			PressureHpa: response.Main.Pressure,
+			PrecipMm:    response.Rain.OneHour,
+			Condition:   condition,
+			FeelsLike_c: response.Main.FeelsLike,
+			FeelsLike_f: temperature.ToFahrenheit(response.Main.FeelsLike),
+		},
+	}, nil
+}
+
+// CheckKey checks the API key is accepted by OpenWeatherMap with a
+// geocoding search, returning an UpstreamAuthError when it is missing or
+// refused.
+func (o *OpenWeatherMapService) CheckKey(ctx context.Context) error {
+	if o.apiKey == "" {
+		return &UpstreamError{Upstream: "openweathermap", Kind: UpstreamAuthError, Provider: OpenWeatherMapServiceError, Err: errors.New("missing API key")}
+	}
+
+	geocodingParams := url.Values{}
+	geocodingParams.Add("q", "São Paulo,,"+CountryBrazil)
+	geocodingParams.Add("limit", "1")
+	geocodingParams.Add("appid", o.apiKey)
+
+	geocoding := OpenWeatherMapGeocodingResponse{}
+	_, err := getJSON(ctx, o.client, "openweathermap", OpenWeatherMapServiceError, o.baseURL+"/geo/1.0/direct?"+geocodingParams.Encode(), &geocoding)
+	return err
+}
+
+// resolve returns the coordinates of the location, searching it with the
+// geocoding API when it was not geocoded.
+func (o *OpenWeatherMapService) resolve(ctx context.Context, location Location) (Coordinates, error) {
+	if location.Coordinates != nil {
+		return *location.Coordinates, nil
+	}
+
+	geocodingParams := url.Values{}
+	geocodingParams.Add("q", location.City+",,"+CountryBrazil)
+	geocodingParams.Add("limit", "5")
+	geocodingParams.Add("appid", o.apiKey)
+
+	o.logger.Println("Requesting coordinates from openweathermap")
+	geocoding := OpenWeatherMapGeocodingResponse{}
+	if _, err := getJSON(ctx, o.client, "openweathermap", OpenWeatherMapServiceError, o.baseURL+"/geo/1.0/direct?"+geocodingParams.Encode(), &geocoding); err != nil {
+		return Coordinates{}, err
+	}
+
+	candidates := make([]locationCandidate, len(geocoding))
+	for i, result := range geocoding {
+		candidates[i] = locationCandidate{Name: result.Name, Region: result.State, Country: result.Country}
+	}
+	i, err := resolveLocation(location, candidates)
+	if err != nil {
+		return Coordinates{}, err
+	}
+
+	return Coordinates{Latitude: geocoding[i].Lat, Longitude: geocoding[i].Lon}, nil
+}
@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
 	"go.opentelemetry.io/otel"
 )
 
@@ -28,11 +30,19 @@ type ViaCepResponse struct {
 	Gia         string `json:"gia"`
 	Ddd         string `json:"ddd"`
 	Siafi       string `json:"siafi"`
+	// Provider names the CepService that answered.
+	Provider string `json:"-"`
+	// Coordinates are set by the providers returning them and by
+	// GeocodingCepService.
+	Coordinates *Coordinates `json:"-"`
 }
 
+const DefaultViaCepBaseURL = "https://viacep.com.br"
+
 type ViaCepService struct {
-	client *http.Client
-	logger *log.Logger
+	baseURL string
+	client  *http.Client
+	logger  *log.Logger
 }
 
 var (
@@ -40,20 +50,33 @@ var (
 	CepNotFoundError = errors.New("cep not found")
 )
 
-func NewViaCepService() *ViaCepService {
+// NewViaCepService returns a ViaCepService calling baseURL,
+// DefaultViaCepBaseURL when empty, with client, one bounded by
+// DefaultUpstreamTimeout when nil.
+func NewViaCepService(baseURL string, client *http.Client) *ViaCepService {
+	if baseURL == "" {
+		baseURL = DefaultViaCepBaseURL
+	}
+
 	return &ViaCepService{
-		client: &http.Client{},
-		logger: log.New(os.Stdout, "ViaCepService: ", log.LstdFlags),
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  upstreamClient(client),
+		logger:  log.New(os.Stdout, "ViaCepService: ", log.LstdFlags),
 	}
 }
 
-func (v *ViaCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCepResponse, error) {
+func (v *ViaCepService) GetAddressByCep(ctx context.Context, cep string) (_ *ViaCepResponse, err error) {
 	tracer := otel.Tracer("a-b-trace")
 	ctx, span := tracer.Start(ctx, "GetAddressByCep - ViaCep")
 	defer span.End()
+	defer func() { recordError(span, err) }()
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() { telemetry.RecordUpstreamCall(ctx, "viacep", outcome, start) }()
 
 	cep = strings.ReplaceAll(cep, "-", "")
-	url := "https://viacep.com.br/ws/" + cep + "/json"
+	url := v.baseURL + "/ws/" + cep + "/json"
 
 	log.Println("Requesting data from Viacep: ", url)
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -63,28 +86,31 @@ func (v *ViaCepService) GetAddressByCep(ctx context.Context, cep string) (*ViaCe
 
 	resp, err := v.client.Do(request)
 	if err != nil {
-		return nil, err
+		return nil, transportError("viacep", CepServiceError, err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, CepServiceError
+		return nil, statusError("viacep", CepServiceError, resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, payloadError("viacep", CepServiceError, err)
 	}
-	defer resp.Body.Close()
 
 	viaCepResponse := &ViaCepResponse{}
 	err = json.Unmarshal(body, &viaCepResponse)
 	if err != nil {
-		return nil, err
+		return nil, payloadError("viacep", CepServiceError, err)
 	}
 
 	if viaCepResponse.Cep == "" {
+		outcome = telemetry.OutcomeNotFound
 		return nil, CepNotFoundError
 	}
 
+	outcome = telemetry.OutcomeSuccess
+	viaCepResponse.Provider = "viacep"
 	return viaCepResponse, nil
 }
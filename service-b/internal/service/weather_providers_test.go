@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+var saoPaulo = Location{City: "São Paulo", Uf: "SP", Ibge: "3550308", Country: CountryBrazil}
+
+func newFixtureServer(t *testing.T, fixtures map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fixture, ok := fixtures[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenMeteoService(t *testing.T) {
+	geocoding := newFixtureServer(t, map[string]string{"/v1/search": "testdata/openmeteo_geocoding.json"})
+	forecast := newFixtureServer(t, map[string]string{"/v1/forecast": "testdata/openmeteo_forecast.json"})
+
+	weather, err := NewOpenMeteoService(forecast.URL, geocoding.URL, nil).GetWeatherByLocation(context.Background(), saoPaulo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if weather.Name != "São Paulo" {
+		t.Errorf("Expected São Paulo, got %s", weather.Name)
+	}
+	if weather.Temp_c != 21.5 || weather.Temp_f != 70.7 {
+		t.Errorf("Expected 21.5C/70.7F, got %vC/%vF", weather.Temp_c, weather.Temp_f)
+	}
+	if expected := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC); !weather.ObservedAt.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, weather.ObservedAt)
+	}
+	if weather.Provider != "openmeteo" {
+		t.Errorf("Expected openmeteo, got %s", weather.Provider)
+	}
+
+	c := weather.Conditions
+	if c == nil {
+		t.Fatal("Expected extended conditions")
+	}
+	if c.Humidity != 60 || c.WindKph != 12.6 || c.WindDir != "SE" || c.PressureHpa != 1018.2 || *c.UV != 5.3 {
+		t.Errorf("Unexpected conditions %+v", c)
+	}
+	if c.Condition.Text != "Mainly clear" || c.Condition.Code != 1 || c.FeelsLike_c != 21.2 {
+		t.Errorf("Unexpected condition %+v feels like %v", c.Condition, c.FeelsLike_c)
+	}
+}
+
+func TestOpenMeteoServiceLocationNotFound(t *testing.T) {
+	geocoding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"generationtime_ms":0.5}`))
+	}))
+	defer geocoding.Close()
+
+	_, err := NewOpenMeteoService(geocoding.URL, geocoding.URL, nil).GetWeatherByLocation(context.Background(), Location{City: "Atlantis", Country: CountryBrazil})
+	if !errors.Is(err, LocationNotFoundError) {
+		t.Errorf("Expected LocationNotFoundError, got %v", err)
+	}
+}
+
+func TestOpenWeatherMapService(t *testing.T) {
+	var query string
+	fixture := newFixtureServer(t, map[string]string{
+		"/geo/1.0/direct":   "testdata/openweathermap_geocoding.json",
+		"/data/2.5/weather": "testdata/openweathermap_weather.json",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data/2.5/weather" {
+			query = r.URL.RawQuery
+		}
+		fixture.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	weather, err := NewOpenWeatherMapService("key", server.URL, nil).GetWeatherByLocation(context.Background(), saoPaulo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "appid=key&lat=-23.5506507&lon=-46.6333824&units=metric"; query != expected {
+		t.Errorf("Expected query %s, got %s", expected, query)
+	}
+	if weather.Temp_c != 21.5 || weather.Temp_f != 70.7 {
+		t.Errorf("Expected 21.5C/70.7F, got %vC/%vF", weather.Temp_c, weather.Temp_f)
+	}
+	if expected := time.Unix(1717243200, 0).UTC(); !weather.ObservedAt.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, weather.ObservedAt)
+	}
+	if weather.Provider != "openweathermap" {
+		t.Errorf("Expected openweathermap, got %s", weather.Provider)
+	}
+
+	c := weather.Conditions
+	if c == nil {
+		t.Fatal("Expected extended conditions")
+	}
+	if c.WindKph != 3.6*3.6 || c.WindDegree != 140 || c.WindDir != "SE" || c.Humidity != 60 || c.UV != nil {
+		t.Errorf("Unexpected conditions %+v", c)
+	}
+	if c.Condition.Text != "clear sky" || c.Condition.Code != 800 {
+		t.Errorf("Unexpected condition %+v", c.Condition)
+	}
+}
+
+func TestOpenWeatherMapServiceErrors(t *testing.T) {
+	expected := map[int]error{
+		http.StatusUnauthorized:        UpstreamAuthError,
+		http.StatusTooManyRequests:     UpstreamRateLimitedError,
+		http.StatusInternalServerError: UpstreamUnavailableError,
+	}
+
+	for statusCode, kind := range expected {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+
+		_, err := NewOpenWeatherMapService("key", server.URL, nil).GetWeatherByLocation(context.Background(), saoPaulo)
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected %v for status %d, got %v", kind, statusCode, err)
+		}
+		server.Close()
+	}
+}
+
+func TestWeatherApiServiceResolvesBrazilianLocation(t *testing.T) {
+	var query string
+	fixture := newFixtureServer(t, map[string]string{
+		"/v1/search.json":  "testdata/weatherapi_search.json",
+		"/v1/current.json": "testdata/weatherapi_current.json",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/current.json" {
+			query = r.URL.Query().Get("q")
+		}
+		fixture.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	service := NewWeatherApiService("key", "", nil)
+	service.baseURL = server.URL
+
+	weather, err := service.GetWeatherByLocation(context.Background(), Location{City: "Santa Maria", Uf: "RS", Ibge: "4316907", Country: CountryBrazil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "-29.68,-53.81" {
+		t.Errorf("Expected the Santa Maria - RS coordinates, got %s", query)
+	}
+	if weather.Temp_c != 14 {
+		t.Errorf("Expected 14, got %v", weather.Temp_c)
+	}
+	if c := weather.Conditions; c == nil || c.Humidity != 82 || c.WindDir != "SE" || c.PrecipMm != 0.2 || c.FeelsLike_f != 55.6 || c.Condition.Code != 1003 {
+		t.Errorf("Unexpected conditions %+v", weather.Conditions)
+	}
+
+	_, err = service.GetWeatherByLocation(context.Background(), Location{City: "Santa Maria", Uf: "SP", Country: CountryBrazil})
+	if !errors.Is(err, LocationNotFoundError) {
+		t.Errorf("Expected LocationNotFoundError for a state without match, got %v", err)
+	}
+}
+
+func TestWeatherProvidersUseCoordinates(t *testing.T) {
+	var queries []string
+	fixture := newFixtureServer(t, map[string]string{
+		"/v1/current.json":  "testdata/weatherapi_current.json",
+		"/v1/forecast":      "testdata/openmeteo_forecast.json",
+		"/data/2.5/weather": "testdata/openweathermap_weather.json",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path)
+		fixture.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	weatherApi := NewWeatherApiService("key", "", nil)
+	weatherApi.baseURL = server.URL
+	location := Location{City: "Santa Maria", Uf: "RS", Country: CountryBrazil, Coordinates: &Coordinates{Latitude: -29.6868, Longitude: -53.8149}}
+
+	for _, provider := range []WeatherService{
+		weatherApi,
+		NewOpenMeteoService(server.URL, server.URL, nil),
+		NewOpenWeatherMapService("key", server.URL, nil),
+	} {
+		if _, err := provider.GetWeatherByLocation(context.Background(), location); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"/v1/current.json", "/v1/forecast", "/data/2.5/weather"}
+	if len(queries) != len(expected) {
+		t.Fatalf("Expected only weather requests %v, got %v", expected, queries)
+	}
+	for i := range expected {
+		if queries[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], queries[i])
+		}
+	}
+}
+
+func TestWeatherApiServiceCheckKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":2008,"message":"API key has been disabled."}}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	for key, expected := range map[string]error{"key": nil, "disabled": UpstreamAuthError, "": UpstreamAuthError} {
+		service := NewWeatherApiService(key, server.URL, nil)
+		if err := service.CheckKey(context.Background()); !errors.Is(err, expected) {
+			t.Errorf("%q: expected %v, got %v", key, expected, err)
+		}
+	}
+}
+
+func TestOpenWeatherMapServiceCheckKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/geo/1.0/direct" {
+			t.Errorf("Expected a geocoding search, got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("appid") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"cod":401,"message":"Invalid API key."}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	for key, expected := range map[string]error{"key": nil, "wrong": UpstreamAuthError, "": UpstreamAuthError} {
+		service := NewOpenWeatherMapService(key, server.URL, nil)
+		if err := service.CheckKey(context.Background()); !errors.Is(err, expected) {
+			t.Errorf("%q: expected %v, got %v", key, expected, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaxForecastDays is the longest forecast WeatherAPI returns.
+const MaxForecastDays = 14
+
+type ForecastService interface {
+	GetForecastByLocation(ctx context.Context, location Location, days int) (*ForecastResponse, error)
+}
+
+type ForecastResponse struct {
+	Name     string        `json:"name"`
+	Days     []ForecastDay `json:"days"`
+	Provider string        `json:"provider"`
+}
+
+type ForecastDay struct {
+	Date         string         `json:"date"`
+	MinTemp_c    float64        `json:"mintemp_c"`
+	MaxTemp_c    float64        `json:"maxtemp_c"`
+	MinTemp_f    float64        `json:"mintemp_f"`
+	MaxTemp_f    float64        `json:"maxtemp_f"`
+	Condition    Condition      `json:"condition"`
+	ChanceOfRain int            `json:"chance_of_rain"`
+	Hours        []ForecastHour `json:"hours"`
+}
+
+type ForecastHour struct {
+	Time         time.Time `json:"time"`
+	Temp_c       float64   `json:"temp_c"`
+	Temp_f       float64   `json:"temp_f"`
+	Condition    Condition `json:"condition"`
+	ChanceOfRain int       `json:"chance_of_rain"`
+}
+
+type Condition struct {
+	Text string `json:"text"`
+	Code int    `json:"code"`
+}
+
+type WeatherApiForecastResponse struct {
+	Location struct {
+		Name    string `json:"name"`
+		Country string `json:"country"`
+	} `json:"location"`
+	Forecast struct {
+		ForecastDay []struct {
+			Date string `json:"date"`
+			Day  struct {
+				MaxTemp_c         float64   `json:"maxtemp_c"`
+				MaxTemp_f         float64   `json:"maxtemp_f"`
+				MinTemp_c         float64   `json:"mintemp_c"`
+				MinTemp_f         float64   `json:"mintemp_f"`
+				DailyChanceOfRain int       `json:"daily_chance_of_rain"`
+				Condition         Condition `json:"condition"`
+			} `json:"day"`
+			Hour []struct {
+				TimeEpoch    int64     `json:"time_epoch"`
+				Temp_c       float64   `json:"temp_c"`
+				Temp_f       float64   `json:"temp_f"`
+				ChanceOfRain int       `json:"chance_of_rain"`
+				Condition    Condition `json:"condition"`
+			} `json:"hour"`
+		} `json:"forecastday"`
+	} `json:"forecast"`
+}
+
+func (w *WeatherApiService) GetForecastByLocation(ctx context.Context, location Location, days int) (_ *ForecastResponse, err error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetForecastByLocation - WeatherAPI")
+	defer span.End()
+	defer func() { recordError(span, err) }()
+	span.SetAttributes(attribute.Int("forecast.days", days))
+
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() {
+		if errors.Is(err, LocationNotFoundError) {
+			outcome = telemetry.OutcomeNotFound
+		}
+		telemetry.RecordUpstreamCall(ctx, "weatherapi", outcome, start)
+	}()
+
+	query, err := w.resolve(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	queryParams := url.Values{}
+	queryParams.Add("q", query)
+	queryParams.Add("days", strconv.Itoa(days))
+	queryParams.Add("aqi", "no")
+	queryParams.Add("alerts", "no")
+
+	log.Println("Requesting forecast from weatherapi.com")
+	forecastResponse := &WeatherApiForecastResponse{}
+	if err := w.get(ctx, "/v1/forecast.json", queryParams, forecastResponse); err != nil {
+		return nil, err
+	}
+
+	if !isBrazil(forecastResponse.Location.Country) {
+		return nil, fmt.Errorf("%w: %s resolved to %s", LocationNotFoundError, location, forecastResponse.Location.Country)
+	}
+
+	forecast := &ForecastResponse{
+		Name:     forecastResponse.Location.Name,
+		Days:     make([]ForecastDay, 0, len(forecastResponse.Forecast.ForecastDay)),
+		Provider: "weatherapi",
+	}
+	for _, forecastDay := range forecastResponse.Forecast.ForecastDay {
+		day := ForecastDay{
+			Date:         forecastDay.Date,
+			MinTemp_c:    forecastDay.Day.MinTemp_c,
+			MaxTemp_c:    forecastDay.Day.MaxTemp_c,
+			MinTemp_f:    forecastDay.Day.MinTemp_f,
+			MaxTemp_f:    forecastDay.Day.MaxTemp_f,
+			Condition:    forecastDay.Day.Condition,
+			ChanceOfRain: forecastDay.Day.DailyChanceOfRain,
+			Hours:        make([]ForecastHour, 0, len(forecastDay.Hour)),
+		}
+		for _, hour := range forecastDay.Hour {
+			day.Hours = append(day.Hours, ForecastHour{
+				Time:         time.Unix(hour.TimeEpoch, 0).UTC(),
+				Temp_c:       hour.Temp_c,
+				Temp_f:       hour.Temp_f,
+				Condition:    hour.Condition,
+				ChanceOfRain: hour.ChanceOfRain,
+			})
+		}
+		forecast.Days = append(forecast.Days, day)
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	return forecast, nil
+}
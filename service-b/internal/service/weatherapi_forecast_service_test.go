@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWeatherApiServiceGetForecast(t *testing.T) {
+	var days string
+	fixture := newFixtureServer(t, map[string]string{"/v1/forecast.json": "testdata/weatherapi_forecast.json"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		days = r.URL.Query().Get("days")
+		fixture.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	service := NewWeatherApiService("key", "", nil)
+	service.baseURL = server.URL
+	location := Location{City: "Santa Maria", Uf: "RS", Country: CountryBrazil, Coordinates: &Coordinates{Latitude: -29.6868, Longitude: -53.8149}}
+
+	forecast, err := service.GetForecastByLocation(context.Background(), location, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days != "2" {
+		t.Errorf("Expected 2 days requested, got %s", days)
+	}
+	if len(forecast.Days) != 2 {
+		t.Fatalf("Expected 2 days, got %d", len(forecast.Days))
+	}
+
+	day := forecast.Days[1]
+	if day.Date != "2024-06-02" || day.MinTemp_c != 12.4 || day.MaxTemp_c != 21 || day.MaxTemp_f != 69.8 {
+		t.Errorf("Unexpected day %+v", day)
+	}
+	if day.Condition.Code != 1063 || day.ChanceOfRain != 86 {
+		t.Errorf("Expected rain, got %+v %d%%", day.Condition, day.ChanceOfRain)
+	}
+	if len(forecast.Days[0].Hours) != 2 {
+		t.Fatalf("Expected 2 hours, got %d", len(forecast.Days[0].Hours))
+	}
+	if hour := forecast.Days[0].Hours[1]; !hour.Time.Equal(time.Unix(1717214400, 0)) || hour.Temp_c != 10.9 {
+		t.Errorf("Unexpected hour %+v", hour)
+	}
+}
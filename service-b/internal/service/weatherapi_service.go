@@ -10,88 +10,260 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/telemetry"
 	"go.opentelemetry.io/otel"
 )
 
 type WeatherService interface {
-	GetWeatherByCity(ctx context.Context, city string) (*WeatherResponse, error)
+	GetWeatherByLocation(ctx context.Context, location Location) (*WeatherResponse, error)
 }
 
+const DefaultWeatherApiBaseURL = "http://api.weatherapi.com"
+
 type WeatherApiService struct {
-	apiKey string
-	client *http.Client
-	logger *log.Logger
+	apiKey  string
+	baseURL string
+	client  *http.Client
+	logger  *log.Logger
+}
+
+type WeatherApiSearchResponse []struct {
+	Id      int64   `json:"id"`
+	Name    string  `json:"name"`
+	Region  string  `json:"region"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
 }
 
 type WeatherApiResponse struct {
 	Location struct {
-		Name string `json:"name"`
+		Name    string `json:"name"`
+		Region  string `json:"region"`
+		Country string `json:"country"`
 	} `json:"location"`
 	Current struct {
-		Temp_c float64 `json:"temp_c"`
-		Temp_f float64 `json:"temp_f"`
+		LastUpdatedEpoch int64     `json:"last_updated_epoch"`
+		Temp_c           float64   `json:"temp_c"`
+		Temp_f           float64   `json:"temp_f"`
+		Condition        Condition `json:"condition"`
+		WindKph          float64   `json:"wind_kph"`
+		WindDegree       int       `json:"wind_degree"`
+		WindDir          string    `json:"wind_dir"`
+		PressureMb       float64   `json:"pressure_mb"`
+		PrecipMm         float64   `json:"precip_mm"`
+		Humidity         float64   `json:"humidity"`
+		FeelsLike_c      float64   `json:"feelslike_c"`
+		FeelsLike_f      float64   `json:"feelslike_f"`
+		UV               float64   `json:"uv"`
 	}
 }
 
+type WeatherApiErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
 type WeatherResponse struct {
 	Name   string  `json:"name"`
 	Temp_c float64 `json:"temp_c"`
 	Temp_f float64 `json:"temp_f"`
+	// ObservedAt is when the provider measured the reading.
+	ObservedAt time.Time `json:"observed_at"`
+	// Stale is set when the reading is a last known value served because
+	// the provider failed.
+	Stale bool `json:"stale"`
+	// Provider names the WeatherService that measured the reading.
+	Provider string `json:"provider"`
+	// Conditions are the extended current conditions, when the provider
+	// reports them.
+	Conditions *Conditions `json:"conditions,omitempty"`
+}
+
+// Conditions are the extended current conditions, converted by every
+// provider to the same units: percent, km/h, degrees, hPa and mm.
+type Conditions struct {
+	Humidity    float64 `json:"humidity"`
+	WindKph     float64 `json:"wind_kph"`
+	WindDegree  int     `json:"wind_degree"`
+	WindDir     string  `json:"wind_dir"`
+	PressureHpa float64 `json:"pressure_hpa"`
+	PrecipMm    float64 `json:"precip_mm"`
+	// UV is nil when the provider does not report the UV index.
+	UV          *float64  `json:"uv,omitempty"`
+	Condition   Condition `json:"condition"`
+	FeelsLike_c float64   `json:"feelslike_c"`
+	FeelsLike_f float64   `json:"feelslike_f"`
 }
 
-func NewWeatherApiService(apiKey string) *WeatherApiService {
+// NewWeatherApiService returns a WeatherApiService calling baseURL,
+// DefaultWeatherApiBaseURL when empty, with client, one bounded by
+// DefaultUpstreamTimeout when nil.
+func NewWeatherApiService(apiKey, baseURL string, client *http.Client) *WeatherApiService {
+	if baseURL == "" {
+		baseURL = DefaultWeatherApiBaseURL
+	}
+
 	return &WeatherApiService{
-		client: &http.Client{},
-		apiKey: apiKey,
-		logger: log.New(os.Stdout, "weatherapi_service: ", log.LstdFlags),
+		client:  upstreamClient(client),
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		logger:  log.New(os.Stdout, "weatherapi_service: ", log.LstdFlags),
 	}
 }
 
 var WeatherServiceError = errors.New("error getting weather")
 
-func (w *WeatherApiService) GetWeatherByCity(ctx context.Context, city string) (*WeatherResponse, error) {
+func (w *WeatherApiService) GetWeatherByLocation(ctx context.Context, location Location) (_ *WeatherResponse, err error) {
 	tracer := otel.Tracer("a-b-trace")
-	ctx, span := tracer.Start(ctx, "GetWeatherByCity - WeatherAPI")
+	ctx, span := tracer.Start(ctx, "GetWeatherByLocation - WeatherAPI")
 	defer span.End()
+	defer func() { recordError(span, err) }()
 
-	queryParams := url.Values{}
-	queryParams.Add("q", city)
-	queryParams.Add("key", w.apiKey)
-	url := fmt.Sprintf("http://api.weatherapi.com/v1/current.json?%s", queryParams.Encode())
+	start := time.Now()
+	outcome := telemetry.OutcomeError
+	defer func() {
+		if errors.Is(err, LocationNotFoundError) {
+			outcome = telemetry.OutcomeNotFound
+		}
+		telemetry.RecordUpstreamCall(ctx, "weatherapi", outcome, start)
+	}()
 
-	log.Println("Requesting weather data from weatherapi.com")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	query, err := w.resolve(ctx, location)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := w.client.Do(req)
-	if err != nil {
+	log.Println("Requesting weather data from weatherapi.com")
+	weatherApiResponse := &WeatherApiResponse{}
+	if err := w.get(ctx, "/v1/current.json", url.Values{"q": {query}}, weatherApiResponse); err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, WeatherServiceError
+	if !isBrazil(weatherApiResponse.Location.Country) {
+		return nil, fmt.Errorf("%w: %s resolved to %s", LocationNotFoundError, location, weatherApiResponse.Location.Country)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	current := weatherApiResponse.Current
+	weatherResponse := &WeatherResponse{
+		Name:       weatherApiResponse.Location.Name,
+		Temp_c:     current.Temp_c,
+		Temp_f:     current.Temp_f,
+		ObservedAt: time.Unix(current.LastUpdatedEpoch, 0).UTC(),
+		Provider:   "weatherapi",
+		Conditions: &Conditions{
+			Humidity:    current.Humidity,
+			WindKph:     current.WindKph,
+			WindDegree:  current.WindDegree,
+			WindDir:     current.WindDir,
+			PressureHpa: current.PressureMb,
+			PrecipMm:    current.PrecipMm,
+			UV:          &current.UV,
+			Condition:   current.Condition,
+			FeelsLike_c: current.FeelsLike_c,
+			FeelsLike_f: current.FeelsLike_f,
+		},
+	}
+
+	outcome = telemetry.OutcomeSuccess
+	return weatherResponse, nil
+}
+
+// CheckKey checks the API key is accepted by WeatherAPI with a city search,
+// returning an UpstreamAuthError when it is missing or refused.
+func (w *WeatherApiService) CheckKey(ctx context.Context) error {
+	if w.apiKey == "" {
+		return &UpstreamError{Upstream: "weatherapi", Kind: UpstreamAuthError, Provider: WeatherServiceError, Err: errors.New("missing API key")}
+	}
+
+	search := WeatherApiSearchResponse{}
+	return w.get(ctx, "/v1/search.json", url.Values{"q": {"São Paulo"}}, &search)
+}
+
+// resolve returns the coordinates of the location as a current.json query,
+// searching it with the WeatherAPI search endpoint when it was not geocoded.
+func (w *WeatherApiService) resolve(ctx context.Context, location Location) (string, error) {
+	if location.Coordinates != nil {
+		return location.Coordinates.String(), nil
+	}
+
+	search := WeatherApiSearchResponse{}
+	if err := w.get(ctx, "/v1/search.json", url.Values{"q": {location.City}}, &search); err != nil {
+		return "", err
+	}
+
+	candidates := make([]locationCandidate, len(search))
+	for i, result := range search {
+		candidates[i] = locationCandidate{Name: result.Name, Region: result.Region, Country: result.Country}
+	}
+
+	i, err := resolveLocation(location, candidates)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return Coordinates{Latitude: search[i].Lat, Longitude: search[i].Lon}.String(), nil
+}
+
+func (w *WeatherApiService) get(ctx context.Context, path string, queryParams url.Values, out any) error {
+	queryParams.Set("key", w.apiKey)
+	url := fmt.Sprintf("%s%s?%s", w.baseURL, path, queryParams.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return transportError("weatherapi", WeatherServiceError, err)
 	}
 	defer resp.Body.Close()
 
-	weatherApiResponse := &WeatherApiResponse{}
-	err = json.Unmarshal(body, &weatherApiResponse)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return payloadError("weatherapi", WeatherServiceError, err)
 	}
 
-	weatherResponse := &WeatherResponse{
-		Name:   weatherApiResponse.Location.Name,
-		Temp_c: weatherApiResponse.Current.Temp_c,
-		Temp_f: weatherApiResponse.Current.Temp_f,
+	if resp.StatusCode != http.StatusOK {
+		return weatherApiError(resp.StatusCode, body)
 	}
 
-	return weatherResponse, nil
+	if err := json.Unmarshal(body, out); err != nil {
+		return payloadError("weatherapi", WeatherServiceError, err)
+	}
+	return nil
+}
+
+// weatherApiError classifies an error answer using the WeatherAPI error
+// codes, see https://www.weatherapi.com/docs/#intro-error-codes.
+func weatherApiError(statusCode int, body []byte) error {
+	errorResponse := &WeatherApiErrorResponse{}
+	if err := json.Unmarshal(body, errorResponse); err != nil {
+		return statusError("weatherapi", WeatherServiceError, statusCode)
+	}
+
+	var kind error
+	switch errorResponse.Error.Code {
+	case 1006:
+		return fmt.Errorf("%w: %s", LocationNotFoundError, errorResponse.Error.Message)
+	case 1002, 2006, 2008, 2009:
+		kind = UpstreamAuthError
+	case 2007:
+		kind = UpstreamRateLimitedError
+	default:
+		return statusError("weatherapi", WeatherServiceError, statusCode)
+	}
+
+	return &UpstreamError{
+		Upstream:   "weatherapi",
+		StatusCode: statusCode,
+		Kind:       kind,
+		Provider:   WeatherServiceError,
+		Err:        errors.New(errorResponse.Error.Message),
+	}
 }
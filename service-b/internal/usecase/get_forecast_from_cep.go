@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+type GetForecastFromCepInput struct {
+	Cep  string
+	Days int
+	// Hourly includes the hourly temperatures of each day.
+	Hourly bool
+}
+
+type Temperature struct {
+	Celsius    float64
+	Fahrenheit float64
+	Kelvin     float64
+}
+
+type GetForecastFromCepOutput struct {
+	City        string
+	Days        []ForecastDayOutput
+	Coordinates *service.Coordinates
+	// ForecastProvider names the ForecastService that made the forecast.
+	ForecastProvider string
+}
+
+type ForecastDayOutput struct {
+	Date          string
+	Min           Temperature
+	Max           Temperature
+	Condition     string
+	ConditionCode int
+	ChanceOfRain  int
+	Hours         []ForecastHourOutput
+}
+
+type ForecastHourOutput struct {
+	Time          time.Time
+	Temperature   Temperature
+	Condition     string
+	ConditionCode int
+	ChanceOfRain  int
+}
+
+type GetForecastFromCepUseCase struct {
+	CepService      service.CepService
+	ForecastService service.ForecastService
+}
+
+func NewGetForecastFromCepUseCase(
+	cepService service.CepService,
+	forecastService service.ForecastService,
+) *GetForecastFromCepUseCase {
+	return &GetForecastFromCepUseCase{
+		CepService:      cepService,
+		ForecastService: forecastService,
+	}
+}
+
+func (u *GetForecastFromCepUseCase) Execute(
+	ctx context.Context,
+	input *GetForecastFromCepInput,
+) (*GetForecastFromCepOutput, error) {
+	tracer := otel.Tracer("a-b-trace")
+	ctx, span := tracer.Start(ctx, "GetForecastFromCepUseCase.Execute")
+	defer span.End()
+
+	address, err := u.CepService.GetAddressByCep(ctx, input.Cep)
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, CepNotFoundError) {
+			span.SetStatus(codes.Error, "getting address")
+		}
+		return nil, err
+	}
+
+	location := service.NewLocation(address)
+	span.SetAttributes(
+		attribute.String("location.city", location.City),
+		attribute.String("location.uf", location.Uf),
+		attribute.String("location.ibge", location.Ibge),
+		attribute.Int("forecast.days", input.Days),
+	)
+	forecast, err := u.ForecastService.GetForecastByLocation(ctx, location, input.Days)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "getting forecast")
+		return nil, err
+	}
+
+	output := &GetForecastFromCepOutput{
+		City:             address.Localidade,
+		Days:             make([]ForecastDayOutput, 0, len(forecast.Days)),
+		Coordinates:      location.Coordinates,
+		ForecastProvider: forecast.Provider,
+	}
+	for _, day := range forecast.Days {
+		dayOutput := ForecastDayOutput{
+			Date:          day.Date,
+			Min:           newTemperature(day.MinTemp_c, day.MinTemp_f),
+			Max:           newTemperature(day.MaxTemp_c, day.MaxTemp_f),
+			Condition:     day.Condition.Text,
+			ConditionCode: day.Condition.Code,
+			ChanceOfRain:  day.ChanceOfRain,
+		}
+		if input.Hourly {
+			for _, hour := range day.Hours {
+				dayOutput.Hours = append(dayOutput.Hours, ForecastHourOutput{
+					Time:          hour.Time,
+					Temperature:   newTemperature(hour.Temp_c, hour.Temp_f),
+					Condition:     hour.Condition.Text,
+					ConditionCode: hour.Condition.Code,
+					ChanceOfRain:  hour.ChanceOfRain,
+				})
+			}
+		}
+		output.Days = append(output.Days, dayOutput)
+	}
+
+	return output, nil
+}
+
+func newTemperature(celsius, fahrenheit float64) Temperature {
+	return Temperature{
+		Celsius:    celsius,
+		Fahrenheit: fahrenheit,
+		Kelvin:     temperature.ToKelvin(celsius),
+	}
+}
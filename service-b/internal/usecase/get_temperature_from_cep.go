@@ -2,9 +2,16 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/coalesce"
+	"github.com/felipemagrassi/lab2-weather-telemetry-app/pkg/temperature"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type GetTemperatureFromCepInput struct {
@@ -16,11 +23,38 @@ type GetTemperatureFromCepOutput struct {
 	Fahrenheit float64
 	Kelvin     float64
 	City       string
+	Uf         string
+	ObservedAt time.Time
+	Stale      bool
+	// CepProvider names the CepService that found the address.
+	CepProvider string
+	// WeatherProvider names the WeatherService that measured the reading.
+	WeatherProvider string
+	// Coordinates of the address, when it was geocoded.
+	Coordinates *service.Coordinates
+	// Conditions are the extended current conditions, when the provider
+	// reports them.
+	Conditions *ConditionsOutput
+}
+
+type ConditionsOutput struct {
+	Humidity      float64
+	WindKph       float64
+	WindDegree    int
+	WindDir       string
+	PressureHpa   float64
+	PrecipMm      float64
+	UV            *float64
+	Condition     string
+	ConditionCode int
+	FeelsLike     Temperature
 }
 
 type GetTemperatureFromCepUseCase struct {
 	CepService     service.CepService
 	WeatherService service.WeatherService
+	// lookups coalesces concurrent executions for the same CEP.
+	lookups *coalesce.Group[*GetTemperatureFromCepOutput]
 }
 
 func NewGetTemperatureFromCepUseCase(
@@ -30,10 +64,23 @@ func NewGetTemperatureFromCepUseCase(
 	return &GetTemperatureFromCepUseCase{
 		CepService:     cepService,
 		WeatherService: weatherService,
+		lookups:        coalesce.NewGroup[*GetTemperatureFromCepOutput]("temperature"),
 	}
 }
 
-var CepNotFoundError = service.CepNotFoundError
+var (
+	CepNotFoundError         = service.CepNotFoundError
+	LocationNotFoundError    = service.LocationNotFoundError
+	UpstreamUnavailableError = service.UpstreamUnavailableError
+	UpstreamTimeoutError     = service.UpstreamTimeoutError
+	UpstreamAuthError        = service.UpstreamAuthError
+	UpstreamRateLimitedError = service.UpstreamRateLimitedError
+	UpstreamBadPayloadError  = service.UpstreamBadPayloadError
+	CircuitOpenError         = service.CircuitOpenError
+
+	NoCepProviderAvailableError     = service.NoCepProviderAvailableError
+	NoWeatherProviderAvailableError = service.NoWeatherProviderAvailableError
+)
 
 func (u *GetTemperatureFromCepUseCase) Execute(
 	ctx context.Context,
@@ -43,18 +90,67 @@ func (u *GetTemperatureFromCepUseCase) Execute(
 	ctx, span := tracer.Start(ctx, "GetTemperatureFromCepUseCase.Execute")
 	defer span.End()
 
-	address, err := u.CepService.GetAddressByCep(ctx, input.Cep)
+	return u.lookups.Do(ctx, input.Cep, func(ctx context.Context) (*GetTemperatureFromCepOutput, error) {
+		// The lookup is shared with the followers and may outlive the
+		// leader's span, so it records on a span of its own, linked to the
+		// leader's one.
+		ctx, span := tracer.Start(ctx, "GetTemperatureFromCepUseCase.lookup", trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(ctx)))
+		defer span.End()
+
+		return u.execute(ctx, input.Cep)
+	})
+}
+
+// execute looks up the temperature of cep, recording on the span of the
+// shared lookup.
+func (u *GetTemperatureFromCepUseCase) execute(ctx context.Context, cep string) (*GetTemperatureFromCepOutput, error) {
+	span := trace.SpanFromContext(ctx)
+
+	address, err := u.CepService.GetAddressByCep(ctx, cep)
 	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, CepNotFoundError) {
+			span.SetStatus(codes.Error, "getting address")
+		}
 		return nil, err
 	}
-	weather, err := u.WeatherService.GetWeatherByCity(ctx, address.Localidade)
+	location := service.NewLocation(address)
+	span.SetAttributes(
+		attribute.String("location.city", location.City),
+		attribute.String("location.uf", location.Uf),
+		attribute.String("location.ibge", location.Ibge),
+	)
+	weather, err := u.WeatherService.GetWeatherByLocation(ctx, location)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "getting weather")
 		return nil, err
 	}
-	return &GetTemperatureFromCepOutput{
-		Celsius:    weather.Temp_c,
-		Fahrenheit: weather.Temp_f,
-		Kelvin:     weather.Temp_c + 273.15,
-		City:       address.Localidade,
-	}, nil
+	output := &GetTemperatureFromCepOutput{
+		Celsius:         weather.Temp_c,
+		Fahrenheit:      weather.Temp_f,
+		Kelvin:          temperature.ToKelvin(weather.Temp_c),
+		City:            address.Localidade,
+		Uf:              location.Uf,
+		ObservedAt:      weather.ObservedAt,
+		Stale:           weather.Stale,
+		CepProvider:     address.Provider,
+		WeatherProvider: weather.Provider,
+		Coordinates:     location.Coordinates,
+	}
+	if c := weather.Conditions; c != nil {
+		output.Conditions = &ConditionsOutput{
+			Humidity:      c.Humidity,
+			WindKph:       c.WindKph,
+			WindDegree:    c.WindDegree,
+			WindDir:       c.WindDir,
+			PressureHpa:   c.PressureHpa,
+			PrecipMm:      c.PrecipMm,
+			UV:            c.UV,
+			Condition:     c.Condition.Text,
+			ConditionCode: c.Condition.Code,
+			FeelsLike:     newTemperature(c.FeelsLike_c, c.FeelsLike_f),
+		}
+	}
+	return output, nil
 }
@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service"
 	"github.com/felipemagrassi/lab2-weather-telemetry-app/service-b/internal/service/mocks"
@@ -18,7 +19,7 @@ func TestGetTemperatureFromCepUseCase(t *testing.T) {
 
 	cepService.
 		EXPECT().
-		GetAddressByCep(ctx, cep).
+		GetAddressByCep(gomock.Any(), cep).
 		Return(&service.ViaCepResponse{
 			Cep:         "12345678",
 			Logradouro:  "Logradouro",
@@ -32,7 +33,12 @@ func TestGetTemperatureFromCepUseCase(t *testing.T) {
 			Siafi:       "Siafi",
 		}, nil)
 
-	weatherService.EXPECT().GetWeatherByCity(ctx, "Localidade").Return(&service.WeatherResponse{
+	weatherService.EXPECT().GetWeatherByLocation(gomock.Any(), service.Location{
+		City:    "Localidade",
+		Uf:      "UF",
+		Ibge:    "Ibge",
+		Country: service.CountryBrazil,
+	}).Return(&service.WeatherResponse{
 		Name:   "Localidade",
 		Temp_c: 10,
 		Temp_f: 50,
@@ -56,3 +62,54 @@ func TestGetTemperatureFromCepUseCase(t *testing.T) {
 		t.Errorf("Expected 283.15, got %v", output.Kelvin)
 	}
 }
+
+func TestGetTemperatureFromCepUseCaseCoalescesLookups(t *testing.T) {
+	controller := gomock.NewController(t)
+	cepService := mocks.NewMockCepService(controller)
+	weatherService := mocks.NewMockWeatherService(controller)
+	cep := "12345678"
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cepService.EXPECT().GetAddressByCep(gomock.Any(), cep).Times(1).
+		DoAndReturn(func(ctx context.Context, cep string) (*service.ViaCepResponse, error) {
+			close(started)
+			<-release
+			return &service.ViaCepResponse{Cep: cep, Localidade: "Localidade", Uf: "SP"}, nil
+		})
+	weatherService.EXPECT().GetWeatherByLocation(gomock.Any(), gomock.Any()).Times(1).
+		Return(&service.WeatherResponse{Temp_c: 10, Temp_f: 50}, nil)
+
+	usecase := NewGetTemperatureFromCepUseCase(cepService, weatherService)
+
+	const requests = 5
+	outputs := make(chan *GetTemperatureFromCepOutput, requests)
+	execute := func() {
+		output, err := usecase.Execute(context.Background(), &GetTemperatureFromCepInput{Cep: cep})
+		if err != nil {
+			t.Errorf("Error: %v", err)
+		}
+		outputs <- output
+	}
+
+	go execute()
+	<-started
+	for i := 1; i < requests; i++ {
+		go execute()
+	}
+	// Release the lookup once every follower joined it.
+	deadline := time.Now().Add(5 * time.Second)
+	for usecase.lookups.Followers(cep) != requests-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d followers, got %d", requests-1, usecase.lookups.Followers(cep))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	for i := 0; i < requests; i++ {
+		if output := <-outputs; output == nil || output.Celsius != 10 {
+			t.Errorf("Expected 10, got %+v", output)
+		}
+	}
+}